@@ -12,7 +12,9 @@ require (
 	github.com/gordonklaus/portaudio v0.0.0-20230709114228-aafa478834f5
 	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/k2-fsa/sherpa-onnx-go v1.1.1
-	github.com/watson-developer-cloud/go-sdk v1.7.0
+	github.com/mewkiz/flac v1.0.12
+	github.com/watson-developer-cloud/go-sdk/v3 v3.0.0
 	golang.org/x/oauth2 v0.15.0
 	google.golang.org/api v0.154.0
+	gopkg.in/hraban/opus.v2 v2.0.0-20230925203106-0188a62cb302
 )
@@ -1,118 +1,667 @@
 package pkg
 
 import (
-	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 	"time"
 
-	"github.com/gordonklaus/portaudio"
 	"github.com/hajimehoshi/go-mp3"
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
 )
 
-// AudioPlayer handles audio playback using PortAudio
+// AudioPlayer decodes audio and plays it through an AudioSink
 type AudioPlayer struct {
-	stream     *portaudio.Stream
-	buffer     []float32
-	playing    bool
-	pauseLock  sync.Mutex
-	paused     bool
-	done       chan struct{}
+	mu      sync.Mutex
+	sink    AudioSink
+	device  string // output device last selected with SetOutputDevice
+	playing bool
+	paused  bool
+	pan     float64
+	effects Effects
+	norm    Normalization
+	outRate float64 // fixed output sample rate, or 0 to follow the source
+	trim    *audio.TrimOptions
+	gap     time.Duration
+	queue   []*utterance
+	done    chan struct{}
+	stop    chan struct{}
+	err     error
+
 	sampleRate float64
+	written    int64 // frames handed to the sink for the current utterance
+	offset     int64 // frames written since the sink was opened, before the current utterance
+	timeline   []timelineMark
+}
+
+// timelineMark maps a sink frame of the current utterance to a time in its
+// source audio. Later sink frames advance the source time at speed.
+type timelineMark struct {
+	frame int64
+	src   time.Duration
+	speed float64
+}
+
+// utterance is decoded audio waiting to be played, with the settings that
+// were current when it was queued
+type utterance struct {
+	dec      streamDecoder
+	src      io.Reader
+	settings pumpSettings
+	gap      time.Duration
+}
+
+// FramePositioner is implemented by sinks that know how many frames of the
+// current utterance have actually reached the output device
+type FramePositioner interface {
+	FramesPlayed() int64
 }
 
-// NewAudioPlayer creates a new audio player instance
+// NewAudioPlayer creates a new audio player for the default PortAudio
+// device. The device is only opened when audio is first played.
 func NewAudioPlayer() (*AudioPlayer, error) {
-	if err := portaudio.Initialize(); err != nil {
-		return nil, fmt.Errorf("failed to initialize PortAudio: %w", err)
+	return NewAudioPlayerWithSink(NewPortAudioSink()), nil
+}
+
+// NewAudioPlayerWithSink creates a new audio player that writes to sink
+func NewAudioPlayerWithSink(sink AudioSink) *AudioPlayer {
+	return &AudioPlayer{sink: sink, effects: NeutralEffects}
+}
+
+// SetSink stops any current playback, closes the current sink and routes
+// subsequent audio to sink
+func (ap *AudioPlayer) SetSink(sink AudioSink) error {
+	if err := ap.Stop(); err != nil {
+		return err
 	}
 
-	return &AudioPlayer{
-		sampleRate: 44100,
-		done:       make(chan struct{}),
-	}, nil
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	old := ap.sink
+	ap.sink = sink
+	ap.device = ""
+	if old != nil && old != sink {
+		return old.Close()
+	}
+	return nil
 }
 
-// PlayMP3Stream plays MP3 audio data from an io.Reader
-func (ap *AudioPlayer) PlayMP3Stream(r io.Reader) error {
-	// Read all audio data into memory
-	audioData, err := io.ReadAll(r)
+// DeviceSelector is implemented by sinks that can play to a chosen output device
+type DeviceSelector interface {
+	SetDevice(deviceID string)
+}
+
+// SetOutputDevice routes subsequent utterances to the output device with
+// the given ID, or to the default device if the ID is empty. If the device
+// later disappears, playback falls back to the default device. The ID is
+// only validated when it changes, against the cached device list.
+func (ap *AudioPlayer) SetOutputDevice(deviceID string) error {
+	ap.mu.Lock()
+	sink, current := ap.sink, ap.device
+	ap.mu.Unlock()
+
+	selector, ok := sink.(DeviceSelector)
+	if !ok {
+		if deviceID == "" {
+			return nil
+		}
+		return fmt.Errorf("audio sink %T does not support device selection", sink)
+	}
+	if deviceID == current {
+		return nil
+	}
+	if deviceID != "" {
+		if err := ValidateDevice(deviceID); err != nil {
+			return err
+		}
+	}
+	selector.SetDevice(deviceID)
+
+	ap.mu.Lock()
+	if ap.sink == sink {
+		ap.device = deviceID
+	}
+	ap.mu.Unlock()
+	return nil
+}
+
+// Sink returns the sink audio is currently written to
+func (ap *AudioPlayer) Sink() AudioSink {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.sink
+}
+
+// SetPan sets the stereo position of subsequent utterances, from -1 (left)
+// through 0 (centre) to 1 (right)
+func (ap *AudioPlayer) SetPan(pan float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.pan = math.Max(-1, math.Min(1, pan))
+}
+
+// Pan returns the stereo position applied to new utterances
+func (ap *AudioPlayer) Pan() float64 {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.pan
+}
+
+// SetSpeed changes the playback speed without changing pitch. It takes
+// effect within one decode chunk, including on the utterance now playing.
+func (ap *AudioPlayer) SetSpeed(speed float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.effects.Speed = speed
+}
+
+// SetPitch shifts the pitch by the given ratio without changing speed
+func (ap *AudioPlayer) SetPitch(pitch float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.effects.Pitch = pitch
+}
+
+// SetGain sets the linear gain applied to playback
+func (ap *AudioPlayer) SetGain(gain float64) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.effects.Gain = gain
+}
+
+// SetEffects replaces the speed, pitch and gain applied to playback
+func (ap *AudioPlayer) SetEffects(fx Effects) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.effects = fx
+}
+
+// Effects returns the speed, pitch and gain applied to playback
+func (ap *AudioPlayer) Effects() Effects {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.effects
+}
+
+// SetNormalization sets the loudness normalization applied to subsequent
+// utterances
+func (ap *AudioPlayer) SetNormalization(n Normalization) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.norm = n
+}
+
+// Normalization returns the loudness normalization applied to new utterances
+func (ap *AudioPlayer) Normalization() Normalization {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.norm
+}
+
+// SetOutputSampleRate resamples subsequent utterances to the given rate, or
+// plays them at their source rate if rate is 0. Either way, audio is
+// resampled if the output device does not support the rate.
+func (ap *AudioPlayer) SetOutputSampleRate(rate int) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.outRate = float64(max(rate, 0))
+}
+
+// SetTrimSilence trims leading and trailing silence from subsequent
+// utterances, or stops trimming if opts is nil
+func (ap *AudioPlayer) SetTrimSilence(opts *audio.TrimOptions) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.trim = opts
+}
+
+// SetGap sets the silence inserted before subsequently enqueued utterances
+// that follow another one in the queue. Audio started with Play, or queued
+// once playback has finished, gets no gap. The gap is written as an exact
+// number of frames at the output rate.
+func (ap *AudioPlayer) SetGap(gap time.Duration) {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	ap.gap = max(gap, 0)
+}
+
+// Play detects the format of the audio in r (WAV, MP3, FLAC or Ogg/Opus) and
+// starts playing it while the rest of r is still being read and decoded.
+// If r is an io.Closer it is closed once decoding finishes.
+func (ap *AudioPlayer) Play(r io.Reader) error {
+	dec, err := newDecoder(r)
 	if err != nil {
-		return fmt.Errorf("failed to read audio data: %w", err)
+		closeReader(r)
+		return fmt.Errorf("failed to decode audio: %w", err)
 	}
+	return ap.start(dec, r)
+}
 
-	// Convert MP3 to PCM
-	pcmData, sampleRate, err := mp3ToPCM(bytes.NewReader(audioData))
+// PlayWAV plays RIFF/WAVE audio data from an io.Reader
+func (ap *AudioPlayer) PlayWAV(r io.Reader) error {
+	dec, err := newWAVDecoder(r)
 	if err != nil {
-		return fmt.Errorf("failed to decode MP3: %w", err)
+		closeReader(r)
+		return fmt.Errorf("failed to decode WAV: %w", err)
 	}
+	return ap.start(dec, r)
+}
 
-	ap.pauseLock.Lock()
-	ap.buffer = pcmData
-	ap.playing = true
-	ap.paused = false
-	ap.sampleRate = sampleRate
-	ap.pauseLock.Unlock()
+// PlayPCM plays interleaved float32 samples in the range [-1, 1]
+func (ap *AudioPlayer) PlayPCM(samples []float32, sampleRate, channels int) error {
+	if len(samples) == 0 {
+		return fmt.Errorf("no audio data")
+	}
+	if sampleRate <= 0 || channels <= 0 {
+		return fmt.Errorf("invalid PCM format: %d Hz, %d channels", sampleRate, channels)
+	}
+	return ap.play(samples, float64(sampleRate), channels)
+}
 
-	// Reset completion channel
-	select {
-	case <-ap.done:
-	default:
+// PlayMP3Stream plays MP3 audio data from an io.Reader, starting as soon as
+// the first frames are decoded
+func (ap *AudioPlayer) PlayMP3Stream(r io.Reader) error {
+	dec, err := newMP3Decoder(r)
+	if err != nil {
+		closeReader(r)
+		return fmt.Errorf("failed to decode MP3: %w", err)
 	}
-	ap.done = make(chan struct{})
+	return ap.start(dec, r)
+}
 
-	// Initialize the PortAudio stream
-	stream, err := portaudio.OpenDefaultStream(0, 1, ap.sampleRate,
-		len(pcmData), ap.buffer)
+// play starts playing decoded samples
+func (ap *AudioPlayer) play(samples []float32, sampleRate float64, channels int) error {
+	return ap.start(&pcmDecoder{samples: samples, sampleRate: sampleRate, channels: channels}, nil)
+}
+
+// Enqueue detects the format of the audio in r like Play, but plays it
+// after the utterances already playing or queued instead of replacing them.
+// An utterance in the same output format as the one before it continues on
+// the open sink, so queued speech plays without a break beyond the gap.
+// The pan, loudness, trim and gap settings are captured when it is queued.
+func (ap *AudioPlayer) Enqueue(r io.Reader) error {
+	dec, err := newDecoder(r)
 	if err != nil {
-		return fmt.Errorf("failed to open audio stream: %w", err)
+		closeReader(r)
+		return fmt.Errorf("failed to decode audio: %w", err)
+	}
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	u := ap.newUtteranceLocked(dec, r)
+	if ap.playing {
+		ap.queue = append(ap.queue, u)
+		return nil
+	}
+	return ap.startLocked(u)
+}
+
+// start opens the sink and decodes dec into it in the background, replacing
+// any utterance that is still playing. Memory use is bounded by the decode
+// chunk and the sink's own buffering, whatever the length of the utterance.
+func (ap *AudioPlayer) start(dec streamDecoder, src io.Reader) error {
+	if err := ap.Stop(); err != nil {
+		closeReader(src)
+		return err
 	}
-	ap.stream = stream
 
-	if err := stream.Start(); err != nil {
-		stream.Close()
-		return fmt.Errorf("failed to start audio stream: %w", err)
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.startLocked(ap.newUtteranceLocked(dec, src))
+}
+
+// newUtteranceLocked captures the current settings for playing dec. The
+// caller must hold mu.
+func (ap *AudioPlayer) newUtteranceLocked(dec streamDecoder, src io.Reader) *utterance {
+	return &utterance{
+		dec:      dec,
+		src:      src,
+		settings: pumpSettings{pan: ap.pan, norm: ap.norm, trim: ap.trim},
+		gap:      ap.gap,
 	}
+}
+
+// formatLocked sets the sink channels and rate u will be played at. The
+// caller must hold mu.
+func (ap *AudioPlayer) formatLocked(u *utterance) {
+	u.settings.out = outputChannels(ap.sink, u.dec.Channels(), u.settings.pan)
+	u.settings.rate = outputRate(ap.sink, u.dec.SampleRate(), ap.outRate)
+}
+
+// startLocked opens the sink for u and plays it, followed by any queued
+// utterances, in the background. The caller must hold mu and nothing may be
+// playing.
+func (ap *AudioPlayer) startLocked(u *utterance) error {
+	if ap.sink == nil {
+		closeReader(u.src)
+		return fmt.Errorf("no audio sink configured")
+	}
+
+	ap.formatLocked(u)
+	if err := ap.sink.Open(u.settings.rate, u.settings.out); err != nil {
+		closeReader(u.src)
+		return err
+	}
+
+	sink := ap.sink
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	ap.done = done
+	ap.stop = stop
+	ap.playing = true
+	ap.paused = false
+	ap.err = nil
+	ap.sampleRate = u.settings.rate
+	ap.written = 0
+	ap.offset = 0
+	ap.timeline = nil
 
-	// Write audio data to the stream in chunks
 	go func() {
-		defer close(ap.done)
-		if err := stream.Write(); err != nil {
-			fmt.Printf("Error writing to audio stream: %v\n", err)
+		defer close(done)
+		err := ap.playQueue(u, sink, stop, done)
+
+		ap.mu.Lock()
+		defer ap.mu.Unlock()
+		if ap.done == done {
+			ap.playing = false
+			ap.paused = false
+			if err != ErrSinkStopped {
+				ap.err = err
+			}
+			ap.clearQueueLocked()
 		}
-		ap.playing = false
 	}()
 
 	return nil
 }
 
-// WaitForCompletion blocks until playback is complete or context is cancelled
+// playQueue plays u and then each utterance queued behind it, until the
+// queue is empty, playback is stopped or an error occurs
+func (ap *AudioPlayer) playQueue(u *utterance, sink AudioSink, stop <-chan struct{}, done chan struct{}) error {
+	for {
+		err := ap.pump(u.dec, sink, u.settings, stop)
+		closeReader(u.src)
+		if err != nil {
+			return err
+		}
+
+		// Continue on the open sink while the format stays the same
+		next := ap.dequeue(done, false)
+		reopen := next == nil || next.settings.rate != u.settings.rate || next.settings.out != u.settings.out
+		if reopen {
+			if err := sink.Drain(); err != nil {
+				return err
+			}
+		}
+		if next == nil {
+			// Audio may have been queued while draining
+			if next = ap.dequeue(done, true); next == nil {
+				return nil
+			}
+		}
+		if reopen {
+			if err := sink.Open(next.settings.rate, next.settings.out); err != nil {
+				closeReader(next.src)
+				return err
+			}
+		}
+
+		ap.mu.Lock()
+		if reopen {
+			ap.offset = 0
+		} else {
+			ap.offset += ap.written
+		}
+		ap.written = 0
+		ap.timeline = nil
+		ap.sampleRate = next.settings.rate
+		ap.mu.Unlock()
+		u = next
+	}
+}
+
+// dequeue pops the next queued utterance and sets its format and gap. It
+// returns nil if the queue is empty or playback was replaced; if last is
+// set, playback is then marked as finished, so later utterances start anew.
+func (ap *AudioPlayer) dequeue(done chan struct{}, last bool) *utterance {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.done != done {
+		return nil
+	}
+	if len(ap.queue) == 0 {
+		if last {
+			ap.playing = false
+			ap.paused = false
+		}
+		return nil
+	}
+
+	u := ap.queue[0]
+	ap.queue[0] = nil
+	ap.queue = ap.queue[1:]
+	ap.formatLocked(u)
+	u.settings.gap = audio.Frames(u.gap, u.settings.rate)
+	return u
+}
+
+// clearQueueLocked discards queued utterances. The caller must hold mu.
+func (ap *AudioPlayer) clearQueueLocked() {
+	for _, u := range ap.queue {
+		closeReader(u.src)
+	}
+	ap.queue = nil
+}
+
+// pumpSettings are the per-utterance settings captured when playback starts
+type pumpSettings struct {
+	out  int     // sink channels
+	rate float64 // sink sample rate
+	pan  float64
+	norm Normalization
+	trim *audio.TrimOptions
+	gap  int // frames of silence before the audio
+}
+
+// pump decodes chunks from dec, trims silence, normalizes their loudness,
+// applies the current effects, resamples them to the output rate, mixes them
+// to the sink's channel layout and writes them until the stream ends or
+// stop is closed
+func (ap *AudioPlayer) pump(dec streamDecoder, sink AudioSink, settings pumpSettings, stop <-chan struct{}) error {
+	channels, out, pan := dec.Channels(), settings.out, settings.pan
+	buf := make([]float32, decodeChunkFrames*channels)
+	proc := newProcessor(dec.SampleRate(), channels)
+	var trimmer *audio.SilenceTrimmer
+	if settings.trim != nil {
+		trimmer = audio.NewSilenceTrimmer(channels, dec.SampleRate(), *settings.trim)
+	}
+	var loudness *normalizer
+	if settings.norm.Enabled {
+		loudness = newNormalizer(dec.SampleRate(), channels, settings.norm)
+	}
+	var resampler *audio.Resampler
+	if settings.rate != dec.SampleRate() {
+		resampler = audio.NewResampler(channels, dec.SampleRate(), settings.rate)
+	}
+
+	// speed is the effect speed of the audio being emitted
+	speed := 1.0
+	emit := func(chunk []float32) error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if out != channels {
+			chunk = remix(chunk, channels, out)
+		}
+		applyPan(chunk, out, pan)
+		if err := sink.Write(chunk); err != nil {
+			return err
+		}
+
+		ap.mu.Lock()
+		defer ap.mu.Unlock()
+		if n := len(ap.timeline); n == 0 {
+			// The source starts after the trimmed lead-in, once the gap has played
+			var lead time.Duration
+			if trimmer != nil {
+				lead = time.Duration(float64(trimmer.Start()) / dec.SampleRate() * float64(time.Second))
+			}
+			ap.timeline = append(ap.timeline, timelineMark{frame: ap.written, src: lead, speed: speed})
+		} else if ap.timeline[n-1].speed != speed {
+			ap.timeline = append(ap.timeline, timelineMark{frame: ap.written, src: ap.sourceTimeLocked(ap.written), speed: speed})
+		}
+		ap.written += int64(len(chunk) / out)
+		return nil
+	}
+	write := func(chunk []float32) error {
+		if resampler != nil {
+			chunk = resampler.Process(chunk)
+		}
+		return emit(chunk)
+	}
+
+	if settings.gap > 0 {
+		if err := sink.Write(make([]float32, settings.gap*out)); err != nil {
+			return err
+		}
+		ap.mu.Lock()
+		ap.written += int64(settings.gap)
+		ap.mu.Unlock()
+	}
+
+	for {
+		select {
+		case <-stop:
+			return ErrSinkStopped
+		default:
+		}
+
+		n, err := dec.Read(buf)
+		fx := ap.Effects()
+		speed = fx.normalized().Speed
+		if n > 0 {
+			chunk := buf[:n]
+			if trimmer != nil {
+				chunk = trimmer.Process(chunk)
+			}
+			if loudness != nil {
+				chunk = loudness.Process(chunk)
+			}
+			if err := write(proc.Process(chunk, fx)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			var rest []float32
+			if trimmer != nil {
+				rest = trimmer.Flush()
+			}
+			if loudness != nil {
+				rest = append(loudness.Process(rest), loudness.Flush()...)
+			}
+			if err := write(proc.Process(rest, fx)); err != nil {
+				return err
+			}
+			if err := write(proc.Flush(fx)); err != nil {
+				return err
+			}
+			if resampler != nil {
+				return emit(resampler.Flush())
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Position returns how far playback of the current utterance has progressed
+func (ap *AudioPlayer) Position() time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	if ap.sampleRate == 0 {
+		return 0
+	}
+	frames := ap.written
+	if positioner, ok := ap.sink.(FramePositioner); ok {
+		frames = max(positioner.FramesPlayed()-ap.offset, 0)
+	}
+	return time.Duration(float64(frames) / ap.sampleRate * float64(time.Second))
+}
+
+// SourcePosition returns how far playback of the current utterance has
+// progressed through its source audio. Unlike Position, it excludes the
+// gap, includes trimmed leading silence and undoes the effect speed, so it
+// can be compared with word timings reported for the synthesized audio.
+func (ap *AudioPlayer) SourcePosition() time.Duration {
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+
+	frames := ap.written
+	if positioner, ok := ap.sink.(FramePositioner); ok {
+		frames = min(max(positioner.FramesPlayed()-ap.offset, 0), ap.written)
+	}
+	return ap.sourceTimeLocked(frames)
+}
+
+// sourceTimeLocked maps a sink frame of the current utterance to source
+// time. The caller must hold mu.
+func (ap *AudioPlayer) sourceTimeLocked(frame int64) time.Duration {
+	if ap.sampleRate == 0 || len(ap.timeline) == 0 || frame < ap.timeline[0].frame {
+		return 0
+	}
+	i := len(ap.timeline) - 1
+	for i > 0 && ap.timeline[i].frame > frame {
+		i--
+	}
+	m := ap.timeline[i]
+	return m.src + time.Duration(float64(frame-m.frame)/ap.sampleRate*m.speed*float64(time.Second))
+}
+
+// closeReader closes r if it is an io.Closer
+func closeReader(r io.Reader) {
+	if c, ok := r.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// WaitForCompletion blocks until playback is complete or stopped and
+// returns any error that occurred while writing to the sink
 func (ap *AudioPlayer) WaitForCompletion() error {
-	if ap.done == nil {
+	ap.mu.Lock()
+	done := ap.done
+	ap.mu.Unlock()
+
+	if done == nil {
 		return fmt.Errorf("no active playback")
 	}
-	<-ap.done
-	return nil
+	<-done
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	return ap.err
 }
 
 // IsPlaying returns true if audio is currently playing
 func (ap *AudioPlayer) IsPlaying() bool {
-	ap.pauseLock.Lock()
-	defer ap.pauseLock.Unlock()
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
 	return ap.playing && !ap.paused
 }
 
 // Pause pauses audio playback
 func (ap *AudioPlayer) Pause() error {
-	ap.pauseLock.Lock()
-	defer ap.pauseLock.Unlock()
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
 
-	if ap.stream == nil || !ap.playing {
+	if !ap.playing {
 		return fmt.Errorf("no active audio playback")
 	}
 
-	if err := ap.stream.Stop(); err != nil {
+	if err := ap.sink.Pause(); err != nil {
 		return fmt.Errorf("failed to pause audio: %w", err)
 	}
 	ap.paused = true
@@ -121,86 +670,100 @@ func (ap *AudioPlayer) Pause() error {
 
 // Resume resumes audio playback
 func (ap *AudioPlayer) Resume() error {
-	ap.pauseLock.Lock()
-	defer ap.pauseLock.Unlock()
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
 
-	if ap.stream == nil || !ap.playing || !ap.paused {
+	if !ap.playing || !ap.paused {
 		return fmt.Errorf("no paused audio playback")
 	}
 
-	if err := ap.stream.Start(); err != nil {
+	if err := ap.sink.Resume(); err != nil {
 		return fmt.Errorf("failed to resume audio: %w", err)
 	}
 	ap.paused = false
 	return nil
 }
 
-// Stop stops audio playback and cleans up resources
+// Stop stops audio playback and waits for the playback goroutine to exit
 func (ap *AudioPlayer) Stop() error {
-	ap.pauseLock.Lock()
-	defer ap.pauseLock.Unlock()
-
-	if ap.stream == nil {
+	ap.mu.Lock()
+	if !ap.playing {
+		ap.mu.Unlock()
 		return nil
 	}
+	done, stop, sink := ap.done, ap.stop, ap.sink
+	close(stop)
+	ap.playing = false
+	ap.paused = false
+	ap.clearQueueLocked()
+	ap.mu.Unlock()
 
-	if err := ap.stream.Stop(); err != nil {
+	if err := sink.Stop(); err != nil {
 		return fmt.Errorf("failed to stop audio: %w", err)
 	}
-
-	if err := ap.stream.Close(); err != nil {
-		return fmt.Errorf("failed to close audio stream: %w", err)
-	}
-
-	ap.stream = nil
-	ap.playing = false
-	ap.paused = false
-	close(ap.done)
+	<-done
 	return nil
 }
 
-// Close cleans up the AudioPlayer resources
+// Close stops playback and releases the sink
 func (ap *AudioPlayer) Close() error {
 	if err := ap.Stop(); err != nil {
 		return err
 	}
-	return portaudio.Terminate()
+
+	ap.mu.Lock()
+	defer ap.mu.Unlock()
+	if ap.sink == nil {
+		return nil
+	}
+	return ap.sink.Close()
 }
 
-// mp3ToPCM converts MP3 data to PCM format using go-mp3
-func mp3ToPCM(r io.Reader) ([]float32, float64, error) {
+// mp3Decoder streams interleaved stereo samples from go-mp3, which always
+// decodes to two channels of 16-bit PCM
+type mp3Decoder struct {
+	decoder *mp3.Decoder
+	raw     []byte
+}
+
+func newMP3Decoder(r io.Reader) (*mp3Decoder, error) {
 	decoder, err := mp3.NewDecoder(r)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create MP3 decoder: %w", err)
+		return nil, fmt.Errorf("failed to create MP3 decoder: %w", err)
 	}
+	return &mp3Decoder{decoder: decoder}, nil
+}
 
-	// Create a buffer for the PCM data
-	var pcmData []float32
-	buffer := make([]byte, 4096)
+func (d *mp3Decoder) SampleRate() float64 { return float64(d.decoder.SampleRate()) }
+func (d *mp3Decoder) Channels() int       { return 2 }
 
-	for {
-		n, err := decoder.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, 0, fmt.Errorf("error reading MP3 data: %w", err)
-		}
+func (d *mp3Decoder) Read(dst []float32) (int, error) {
+	if cap(d.raw) < len(dst)*2 {
+		d.raw = make([]byte, len(dst)*2)
+	}
+	raw := d.raw[:len(dst)*2]
 
-		// Convert each pair of bytes (16-bit samples) to float32
-		for i := 0; i < n; i += 2 {
-			if i+1 >= n {
-				break
-			}
-			// Convert 16-bit PCM to float32 (-1.0 to 1.0)
-			sample := float32(int16(buffer[i])|int16(buffer[i+1])<<8) / 32768.0
-			pcmData = append(pcmData, sample)
-		}
+	n, err := io.ReadFull(d.decoder, raw)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("error reading MP3 data: %w", err)
 	}
 
-	if len(pcmData) == 0 {
-		return nil, 0, fmt.Errorf("no audio data decoded")
+	// Convert each pair of bytes (16-bit samples) to float32 (-1.0 to 1.0)
+	samples := n / 4 * 2
+	for i := 0; i < samples; i++ {
+		dst[i] = float32(int16(raw[2*i])|int16(raw[2*i+1])<<8) / 32768.0
 	}
+	if samples > 0 {
+		return samples, nil
+	}
+	return 0, err
+}
 
-	return pcmData, float64(decoder.SampleRate()), nil
+// mp3ToPCM converts MP3 data to interleaved stereo PCM using go-mp3
+func mp3ToPCM(r io.Reader) ([]float32, float64, error) {
+	samples, sampleRate, _, err := decodeAll(newMP3Decoder(r))
+	return samples, sampleRate, err
 }
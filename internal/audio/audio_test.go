@@ -14,8 +14,12 @@ func TestNewAudioPlayer(t *testing.T) {
 	}
 	defer player.Close()
 
-	if player.stream != nil {
-		t.Error("Expected initial stream to be nil")
+	sink, ok := player.sink.(*PortAudioSink)
+	if !ok {
+		t.Fatalf("Expected default sink to be *PortAudioSink, got %T", player.sink)
+	}
+	if sink.initialized || sink.stream != nil {
+		t.Error("Expected PortAudio to stay closed until playback starts")
 	}
 	if player.playing {
 		t.Error("Expected initial playing state to be false")
@@ -0,0 +1,183 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+func makeFLAC(t *testing.T, left, right []int32, sampleRate uint32) []byte {
+	t.Helper()
+	// FLAC requires blocks of at least 16 samples
+	for len(left) < 16 {
+		left = append(left, 0)
+		right = append(right, 0)
+	}
+
+	var buf bytes.Buffer
+	info := &meta.StreamInfo{
+		BlockSizeMin:  uint16(len(left)),
+		BlockSizeMax:  uint16(len(left)),
+		SampleRate:    sampleRate,
+		NChannels:     2,
+		BitsPerSample: 16,
+		NSamples:      uint64(len(left)),
+	}
+	enc, err := flac.NewEncoder(&buf, info)
+	if err != nil {
+		t.Fatalf("NewEncoder failed: %v", err)
+	}
+	f := &frame.Frame{
+		Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(len(left)),
+			SampleRate:        sampleRate,
+			Channels:          frame.ChannelsLR,
+			BitsPerSample:     16,
+		},
+		Subframes: []*frame.Subframe{
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: left, NSamples: len(left)},
+			{SubHeader: frame.SubHeader{Pred: frame.PredVerbatim}, Samples: right, NSamples: len(right)},
+		},
+	}
+	if err := enc.WriteFrame(f); err != nil {
+		t.Fatalf("WriteFrame failed: %v", err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeFLAC(t *testing.T) {
+	data := makeFLAC(t, []int32{0, 16384, -16384, 8192}, []int32{100, -100, 200, -200}, 44100)
+
+	samples, rate, channels, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if rate != 44100 || channels != 2 {
+		t.Errorf("Expected 44100 Hz stereo, got %.0f Hz, %d channels", rate, channels)
+	}
+	want := []float32{0, 100.0 / 32768, 0.5, -100.0 / 32768, -0.5, 200.0 / 32768, 0.25, -200.0 / 32768}
+	if len(samples) != 32 {
+		t.Fatalf("Expected 32 samples, got %d", len(samples))
+	}
+	for i := range want {
+		if math.Abs(float64(samples[i]-want[i])) > 1e-6 {
+			t.Errorf("Sample %d: expected %f, got %f", i, want[i], samples[i])
+		}
+	}
+}
+
+func TestConvertToWAV(t *testing.T) {
+	data := makeFLAC(t, []int32{0, 16384}, []int32{0, -16384}, 22050)
+
+	var wav bytes.Buffer
+	if err := ConvertToWAV(&wav, bytes.NewReader(data)); err != nil {
+		t.Fatalf("ConvertToWAV failed: %v", err)
+	}
+	samples, rate, channels, err := decodeWAV(&wav)
+	if err != nil {
+		t.Fatalf("decodeWAV failed: %v", err)
+	}
+	if rate != 22050 || channels != 2 || len(samples) != 32 {
+		t.Errorf("Unexpected WAV: %.0f Hz, %d channels, %d samples", rate, channels, len(samples))
+	}
+}
+
+// oggPage builds an Ogg page from segment lacing values and a body
+func oggPage(headerType byte, granule int64, seq uint32, lacing []byte, body []byte) []byte {
+	page := make([]byte, 27, 27+len(lacing)+len(body))
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(page[14:], 1)
+	binary.LittleEndian.PutUint32(page[18:], seq)
+	page[26] = byte(len(lacing))
+	page = append(page, lacing...)
+	page = append(page, body...)
+	binary.LittleEndian.PutUint32(page[22:], oggCRC(0, page))
+	return page
+}
+
+func TestOggReaderJoinsContinuedPackets(t *testing.T) {
+	long := bytes.Repeat([]byte{7}, 300)
+	var stream []byte
+	// The 300-byte packet is split as 255 bytes on page 0 and 45 on page 1
+	stream = append(stream, oggPage(0x02, 0, 0, []byte{3, 255}, append([]byte("abc"), long[:255]...))...)
+	stream = append(stream, oggPage(0x01|0x04, 10, 1, []byte{45, 2}, append(long[255:], "de"...))...)
+
+	ogg := newOggReader(bytes.NewReader(stream))
+	var packets [][]byte
+	for {
+		p, err := ogg.NextPacket()
+		if err != nil {
+			break
+		}
+		packets = append(packets, p)
+	}
+	if len(packets) != 3 {
+		t.Fatalf("Expected 3 packets, got %d", len(packets))
+	}
+	if string(packets[0]) != "abc" || !bytes.Equal(packets[1], long) || string(packets[2]) != "de" {
+		t.Errorf("Unexpected packets: %q", packets)
+	}
+	if ogg.granule != 10 {
+		t.Errorf("Expected granule 10, got %d", ogg.granule)
+	}
+
+	stream[30] ^= 0xFF
+	if _, err := newOggReader(bytes.NewReader(stream)).NextPacket(); err == nil {
+		t.Error("Expected checksum error for corrupted page")
+	}
+}
+
+type fakeOpusDecoder struct{ channels int }
+
+func (d fakeOpusDecoder) DecodeFloat32(packet []byte, pcm []float32) (int, error) {
+	for i := 0; i < 960*d.channels; i++ {
+		pcm[i] = 0.25
+	}
+	return 960, nil
+}
+
+func TestDecodeOggOpus(t *testing.T) {
+	defer func(orig func(int, int) (opusPacketDecoder, error)) { newOpusDecoder = orig }(newOpusDecoder)
+	newOpusDecoder = func(sampleRate, channels int) (opusPacketDecoder, error) {
+		return fakeOpusDecoder{channels}, nil
+	}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = 1                                     // mono
+	binary.LittleEndian.PutUint16(head[10:], 312)   // pre-skip
+	binary.LittleEndian.PutUint16(head[16:], 6*256) // +6 dB output gain
+	tags := []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	var stream []byte
+	stream = append(stream, oggPage(0x02, 0, 0, []byte{19}, head)...)
+	stream = append(stream, oggPage(0, 0, 1, []byte{byte(len(tags))}, tags)...)
+	// Two 20 ms packets, with the final granule trimming the last 100 samples
+	stream = append(stream, oggPage(0x04, 2*960-100, 2, []byte{1, 1}, []byte{0, 0})...)
+
+	samples, rate, channels, err := Decode(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if rate != 48000 || channels != 1 {
+		t.Errorf("Expected 48000 Hz mono, got %.0f Hz, %d channels", rate, channels)
+	}
+	if want := 2*960 - 100 - 312; len(samples) != want {
+		t.Errorf("Expected %d samples after trimming, got %d", want, len(samples))
+	}
+	if math.Abs(float64(samples[0])-0.25*math.Pow(10, 6.0/20)) > 1e-4 {
+		t.Errorf("Expected output gain to be applied, got %f", samples[0])
+	}
+}
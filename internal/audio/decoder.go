@@ -0,0 +1,91 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// decodeChunkFrames is the number of frames decoded and written at a time
+const decodeChunkFrames = 2048
+
+// streamDecoder decodes audio incrementally. The header is parsed when the
+// decoder is created; Read fills dst with interleaved samples and returns
+// io.EOF once the stream is exhausted.
+type streamDecoder interface {
+	SampleRate() float64
+	Channels() int
+	Read(dst []float32) (int, error)
+}
+
+// newDecoder sniffs the container format of r and returns a matching decoder
+func newDecoder(r io.Reader) (streamDecoder, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(12)
+
+	switch detectFormat(header) {
+	case formatWAV:
+		return newWAVDecoder(br)
+	case formatMP3:
+		return newMP3Decoder(br)
+	case formatFLAC:
+		return newFLACDecoder(br)
+	case formatOgg:
+		return newOggOpusDecoder(br)
+	}
+	if len(header) == 0 {
+		return nil, fmt.Errorf("no audio data")
+	}
+	return nil, fmt.Errorf("unrecognized audio format")
+}
+
+// readAll decodes the rest of the stream into memory
+func readAll(dec streamDecoder) ([]float32, error) {
+	var samples []float32
+	buf := make([]float32, decodeChunkFrames*dec.Channels())
+	for {
+		n, err := dec.Read(buf)
+		samples = append(samples, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no audio data decoded")
+	}
+	return samples, nil
+}
+
+// decodeAll fully decodes the stream of a newly created decoder
+func decodeAll(dec streamDecoder, err error) ([]float32, float64, int, error) {
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	samples, err := readAll(dec)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return samples, dec.SampleRate(), dec.Channels(), nil
+}
+
+// pcmDecoder serves already decoded samples
+type pcmDecoder struct {
+	samples    []float32
+	sampleRate float64
+	channels   int
+}
+
+func (d *pcmDecoder) SampleRate() float64 { return d.sampleRate }
+func (d *pcmDecoder) Channels() int       { return d.channels }
+
+func (d *pcmDecoder) Read(dst []float32) (int, error) {
+	if len(d.samples) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(dst, d.samples)
+	d.samples = d.samples[n:]
+	return n, nil
+}
@@ -0,0 +1,304 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// OutputDevice describes an audio output device
+type OutputDevice struct {
+	ID                 string // Stable "<host API>/<device name>" identifier
+	Name               string
+	HostAPI            string
+	IsDefault          bool
+	MaxInputChannels   int
+	MaxOutputChannels  int
+	DefaultLowLatency  time.Duration // Default output latency for interactive use
+	DefaultHighLatency time.Duration // Default output latency for robust playback
+	DefaultSampleRate  float64
+	SampleRates        []int // Rates accepted by the device for float32 output
+}
+
+// probeRates are the sample rates tested with IsFormatSupported
+var probeRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000, 88200, 96000, 192000}
+
+// deviceCache holds the last scan so listing devices doesn't initialize
+// PortAudio and probe every device on each call
+var deviceCache struct {
+	mu      sync.Mutex
+	devices []OutputDevice
+	scanned bool
+	rates   map[string][]int // probed rates by device ID and default rate
+}
+
+var (
+	defaultDeviceMu sync.Mutex
+	defaultDeviceID string
+)
+
+// SetDefaultDevice sets the output device used by PortAudio sinks that have
+// no device of their own. An empty ID restores the system default.
+func SetDefaultDevice(deviceID string) {
+	defaultDeviceMu.Lock()
+	defer defaultDeviceMu.Unlock()
+	defaultDeviceID = deviceID
+}
+
+// DefaultDevice returns the ID set with SetDefaultDevice
+func DefaultDevice() string {
+	defaultDeviceMu.Lock()
+	defer defaultDeviceMu.Unlock()
+	return defaultDeviceID
+}
+
+// DeviceIDs returns a stable ID for each device of the form
+// "<host API>/<device name>". Device indexes change as hardware comes and
+// goes, and names repeat across host APIs, so neither is usable on its
+// own. Devices sharing a name within a host API get a "#2", "#3" suffix.
+func DeviceIDs(devices []*portaudio.DeviceInfo) []string {
+	ids := make([]string, len(devices))
+	seen := make(map[string]int)
+	for i, dev := range devices {
+		api := "unknown"
+		if dev.HostApi != nil {
+			api = dev.HostApi.Name
+		}
+		id := api + "/" + dev.Name
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id = fmt.Sprintf("%s #%d", id, n)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// findOutputDevice returns the output device with the given ID. A bare
+// device name is accepted too, matching the first output device with that
+// name. PortAudio must be initialized.
+func findOutputDevice(deviceID string) (*portaudio.DeviceInfo, string, error) {
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get devices: %w", err)
+	}
+	ids := DeviceIDs(devices)
+	for i, dev := range devices {
+		if dev.MaxOutputChannels > 0 && ids[i] == deviceID {
+			return dev, ids[i], nil
+		}
+	}
+	if !strings.Contains(deviceID, "/") {
+		for i, dev := range devices {
+			if dev.MaxOutputChannels > 0 && dev.Name == deviceID {
+				return dev, ids[i], nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("output device not found: %s", deviceID)
+}
+
+// ValidateDevice returns an error unless the last device scan found an
+// output device with the given ID. A bare device name is accepted too. It
+// checks the cached list, so it never cycles PortAudio while a stream may be
+// open; call RescanOutputDevices to pick up newly connected hardware.
+func ValidateDevice(deviceID string) error {
+	devices, err := OutputDevices()
+	if err != nil {
+		return err
+	}
+	if !hasOutputDevice(devices, deviceID) {
+		return fmt.Errorf("output device not found: %s", deviceID)
+	}
+	return nil
+}
+
+// hasOutputDevice reports whether devices includes deviceID, matching IDs
+// and, for an ID without a host API, device names like findOutputDevice
+func hasOutputDevice(devices []OutputDevice, deviceID string) bool {
+	bareName := !strings.Contains(deviceID, "/")
+	for _, d := range devices {
+		if d.ID == deviceID || (bareName && d.Name == deviceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputDevices returns the output devices found by the last scan, scanning
+// on first use
+func OutputDevices() ([]OutputDevice, error) {
+	deviceCache.mu.Lock()
+	defer deviceCache.mu.Unlock()
+
+	if !deviceCache.scanned {
+		if err := rescanLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return slices.Clone(deviceCache.devices), nil
+}
+
+// RescanOutputDevices enumerates the output devices again. PortAudio only
+// sees hardware changes while no stream holds it open, and PortAudio sinks
+// release it a few seconds after playback ends.
+func RescanOutputDevices() ([]OutputDevice, error) {
+	deviceCache.mu.Lock()
+	defer deviceCache.mu.Unlock()
+
+	if err := rescanLocked(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(deviceCache.devices), nil
+}
+
+func rescanLocked() error {
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize PortAudio: %w", err)
+	}
+	defer portaudio.Terminate()
+
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+	defaultDevice, _ := portaudio.DefaultOutputDevice()
+	ids := DeviceIDs(devices)
+
+	if deviceCache.rates == nil {
+		deviceCache.rates = make(map[string][]int)
+	}
+
+	outputs := make([]OutputDevice, 0, len(devices))
+	for i, dev := range devices {
+		if dev.MaxOutputChannels == 0 {
+			continue
+		}
+		d := OutputDevice{
+			ID:                 ids[i],
+			Name:               dev.Name,
+			IsDefault:          dev == defaultDevice,
+			MaxInputChannels:   dev.MaxInputChannels,
+			MaxOutputChannels:  dev.MaxOutputChannels,
+			DefaultLowLatency:  dev.DefaultLowOutputLatency,
+			DefaultHighLatency: dev.DefaultHighOutputLatency,
+			DefaultSampleRate:  dev.DefaultSampleRate,
+		}
+		if dev.HostApi != nil {
+			d.HostAPI = dev.HostApi.Name
+		}
+
+		// Probing opens the device driver, so reuse earlier results for a
+		// device that is still present with the same configuration
+		key := fmt.Sprintf("%s@%.0f", d.ID, d.DefaultSampleRate)
+		rates, ok := deviceCache.rates[key]
+		if !ok {
+			rates = probeSampleRates(dev)
+			deviceCache.rates[key] = rates
+		}
+		d.SampleRates = slices.Clone(rates)
+		outputs = append(outputs, d)
+	}
+
+	deviceCache.devices = outputs
+	deviceCache.scanned = true
+	return nil
+}
+
+// probeSampleRates returns the rates at which dev accepts float32 output
+func probeSampleRates(dev *portaudio.DeviceInfo) []int {
+	var rates []int
+	for _, rate := range probeRates {
+		params := portaudio.HighLatencyParameters(nil, dev)
+		params.Output.Channels = min(dev.MaxOutputChannels, 2)
+		params.SampleRate = float64(rate)
+		if portaudio.IsFormatSupported(params, func(out []float32) {}) == nil {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+// DeviceChange reports a change in the available output devices
+type DeviceChange struct {
+	Devices        []OutputDevice // The devices now available
+	Added          []OutputDevice
+	Removed        []OutputDevice
+	DefaultChanged bool // The system default output device changed
+}
+
+// WatchOutputDevices rescans the output devices every interval and sends a
+// DeviceChange whenever devices appear, disappear or the default changes.
+// The channel is closed when ctx is done.
+func WatchOutputDevices(ctx context.Context, interval time.Duration) <-chan DeviceChange {
+	changes := make(chan DeviceChange)
+
+	go func() {
+		defer close(changes)
+
+		previous, _ := OutputDevices()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := RescanOutputDevices()
+			if err != nil {
+				continue
+			}
+			change, changed := diffDevices(previous, current)
+			if !changed {
+				continue
+			}
+			previous = current
+
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes
+}
+
+// diffDevices compares two scans by device ID
+func diffDevices(previous, current []OutputDevice) (DeviceChange, bool) {
+	change := DeviceChange{Devices: current}
+	before := make(map[string]OutputDevice, len(previous))
+	after := make(map[string]OutputDevice, len(current))
+	var oldDefault, newDefault string
+	for _, d := range previous {
+		before[d.ID] = d
+		if d.IsDefault {
+			oldDefault = d.ID
+		}
+	}
+	for _, d := range current {
+		after[d.ID] = d
+		if d.IsDefault {
+			newDefault = d.ID
+		}
+		if _, ok := before[d.ID]; !ok {
+			change.Added = append(change.Added, d)
+		}
+	}
+	for _, d := range previous {
+		if _, ok := after[d.ID]; !ok {
+			change.Removed = append(change.Removed, d)
+		}
+	}
+	change.DefaultChanged = oldDefault != newDefault
+	return change, len(change.Added) > 0 || len(change.Removed) > 0 || change.DefaultChanged
+}
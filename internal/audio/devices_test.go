@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+func TestDeviceIDs(t *testing.T) {
+	alsa := &portaudio.HostApiInfo{Name: "ALSA"}
+	jack := &portaudio.HostApiInfo{Name: "JACK Audio Connection Kit"}
+	devices := []*portaudio.DeviceInfo{
+		{Name: "USB Headset", HostApi: alsa},
+		{Name: "USB Headset", HostApi: jack},
+		{Name: "USB Headset", HostApi: alsa},
+		{Name: "Orphan"},
+	}
+
+	want := []string{
+		"ALSA/USB Headset",
+		"JACK Audio Connection Kit/USB Headset",
+		"ALSA/USB Headset #2",
+		"unknown/Orphan",
+	}
+	if got := DeviceIDs(devices); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSetOutputDeviceRequiresSelector(t *testing.T) {
+	player := NewAudioPlayerWithSink(NewMemorySink())
+	defer player.Close()
+
+	if err := player.SetOutputDevice(""); err != nil {
+		t.Errorf("Expected default device to be accepted by any sink, got %v", err)
+	}
+	if err := player.SetOutputDevice("ALSA/USB Headset"); err == nil {
+		t.Error("Expected error selecting a device on a memory sink")
+	}
+}
+
+func TestPortAudioSinkSetDevice(t *testing.T) {
+	sink := NewPortAudioSinkForDevice("ALSA/Speakers")
+	sink.SetDevice("ALSA/Speakers")
+	if sink.reopen {
+		t.Error("Expected selecting the same device not to reopen the stream")
+	}
+	sink.SetDevice("ALSA/Headphones")
+	if !sink.reopen || sink.deviceID != "ALSA/Headphones" {
+		t.Error("Expected new device to be used for the next utterance")
+	}
+}
+
+func TestDiffDevices(t *testing.T) {
+	speakers := OutputDevice{ID: "ALSA/Speakers", IsDefault: true}
+	headphones := OutputDevice{ID: "ALSA/Headphones"}
+
+	if _, changed := diffDevices([]OutputDevice{speakers}, []OutputDevice{speakers}); changed {
+		t.Error("Expected no change for identical scans")
+	}
+
+	headphonesDefault := headphones
+	headphonesDefault.IsDefault = true
+	speakersNotDefault := speakers
+	speakersNotDefault.IsDefault = false
+
+	change, changed := diffDevices(
+		[]OutputDevice{speakers},
+		[]OutputDevice{speakersNotDefault, headphonesDefault},
+	)
+	if !changed || !change.DefaultChanged {
+		t.Fatal("Expected a default device change")
+	}
+	if len(change.Added) != 1 || change.Added[0].ID != headphones.ID || len(change.Removed) != 0 {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+
+	change, _ = diffDevices([]OutputDevice{speakers, headphones}, []OutputDevice{speakers})
+	if len(change.Removed) != 1 || change.Removed[0].ID != headphones.ID {
+		t.Errorf("Expected headphones to be removed, got %+v", change)
+	}
+}
+
+type selectorSink struct {
+	*MemorySink
+	selected []string
+}
+
+func (s *selectorSink) SetDevice(deviceID string) { s.selected = append(s.selected, deviceID) }
+
+func TestSetOutputDeviceUsesCachedDevices(t *testing.T) {
+	deviceCache.mu.Lock()
+	saved := deviceCache.devices
+	savedScanned := deviceCache.scanned
+	deviceCache.devices = []OutputDevice{{ID: "ALSA/Speakers", Name: "Speakers"}}
+	deviceCache.scanned = true
+	deviceCache.mu.Unlock()
+	defer func() {
+		deviceCache.mu.Lock()
+		deviceCache.devices, deviceCache.scanned = saved, savedScanned
+		deviceCache.mu.Unlock()
+	}()
+
+	sink := &selectorSink{MemorySink: NewMemorySink()}
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := player.SetOutputDevice("ALSA/Speakers"); err != nil {
+			t.Fatalf("SetOutputDevice failed: %v", err)
+		}
+	}
+	if err := player.SetOutputDevice("Speakers"); err != nil {
+		t.Errorf("Expected a bare device name to be accepted, got %v", err)
+	}
+	if err := player.SetOutputDevice("ALSA/Headphones"); err == nil {
+		t.Error("Expected error selecting a device missing from the scan")
+	}
+	if want := []string{"ALSA/Speakers", "Speakers"}; !reflect.DeepEqual(sink.selected, want) {
+		t.Errorf("Expected the device to be selected only when it changes, got %q", sink.selected)
+	}
+}
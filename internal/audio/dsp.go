@@ -0,0 +1,263 @@
+package pkg
+
+import (
+	"math"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+// Effects are playback-time adjustments applied to decoded audio before it
+// reaches the sink. A zero Speed or Pitch is treated as 1.
+type Effects struct {
+	Speed float64 // Playback speed without changing pitch (1.0 is normal)
+	Pitch float64 // Pitch ratio without changing speed (1.0 is normal, 2.0 is an octave up)
+	Gain  float64 // Linear gain (1.0 is unchanged)
+}
+
+// NeutralEffects leaves audio unchanged
+var NeutralEffects = Effects{Speed: 1, Pitch: 1, Gain: 1}
+
+func (fx Effects) normalized() Effects {
+	if fx.Speed <= 0 {
+		fx.Speed = 1
+	}
+	if fx.Pitch <= 0 {
+		fx.Pitch = 1
+	}
+	if fx.Gain < 0 {
+		fx.Gain = 0
+	}
+	fx.Speed = math.Max(0.25, math.Min(4, fx.Speed))
+	fx.Pitch = math.Max(0.25, math.Min(4, fx.Pitch))
+	return fx
+}
+
+// processor applies Effects to a stream of interleaved samples. Pitch is
+// shifted by time-stretching with WSOLA and then resampling with the
+// band-limited audio.Resampler, so speed and pitch can change independently
+// and at any point in the stream.
+type processor struct {
+	sampleRate float64
+	channels   int
+	stretch    *wsola
+	pitch      float64          // pitch ratio the resampler was created for
+	resampler  *audio.Resampler // nil while the pitch is unchanged
+	active     bool             // stretching has engaged; keep it so the stream stays continuous
+}
+
+func newProcessor(sampleRate float64, channels int) *processor {
+	return &processor{
+		sampleRate: sampleRate,
+		channels:   channels,
+		stretch:    newWSOLA(sampleRate, channels),
+		pitch:      1,
+	}
+}
+
+// Process returns the processed form of in. It may buffer audio internally,
+// so the output can be shorter or longer than the input.
+func (p *processor) Process(in []float32, fx Effects) []float32 {
+	fx = fx.normalized()
+	if !p.active && fx.Speed == 1 && fx.Pitch == 1 {
+		return applyGain(in, fx.Gain)
+	}
+	p.active = true
+
+	out := p.stretch.Process(in, fx.Speed/fx.Pitch)
+	out = p.shift(out, fx.Pitch)
+	return applyGain(out, fx.Gain)
+}
+
+// shift resamples in so that pitch input frames play as one output frame.
+// When the pitch changes, the old resampler is flushed and a new one
+// started for the new ratio.
+func (p *processor) shift(in []float32, pitch float64) []float32 {
+	var out []float32
+	if pitch != p.pitch {
+		if p.resampler != nil {
+			out = p.resampler.Flush()
+			p.resampler = nil
+		}
+		p.pitch = pitch
+		if pitch != 1 {
+			p.resampler = audio.NewResampler(p.channels, p.sampleRate*pitch, p.sampleRate)
+		}
+	}
+	if p.resampler == nil {
+		return append(out, in...)
+	}
+	return append(out, p.resampler.Process(in)...)
+}
+
+// Flush returns any audio still buffered at the end of the stream
+func (p *processor) Flush(fx Effects) []float32 {
+	if !p.active {
+		return nil
+	}
+	fx = fx.normalized()
+	out := p.shift(p.stretch.Flush(), fx.Pitch)
+	if p.resampler != nil {
+		out = append(out, p.resampler.Flush()...)
+		p.resampler = nil
+		p.pitch = 1
+	}
+	return applyGain(out, fx.Gain)
+}
+
+// applyGain scales samples by gain, clipping them to [-1, 1] so a boost
+// cannot wrap around when converted to integer samples
+func applyGain(samples []float32, gain float64) []float32 {
+	if gain == 1 {
+		return samples
+	}
+	g := float32(gain)
+	for i := range samples {
+		samples[i] = max(-1, min(1, samples[i]*g))
+	}
+	return samples
+}
+
+// wsola time-stretches audio with Waveform Similarity Overlap-Add: windows
+// are read from the input at a hop scaled by the tempo, each shifted within
+// a small tolerance to line up with the waveform that naturally followed the
+// previous window, and overlap-added at a fixed hop.
+type wsola struct {
+	channels  int
+	window    int // window length in frames
+	hop       int // synthesis hop (half the window)
+	tolerance int // search range around the nominal position, in frames
+	hann      []float32
+
+	in      []float32 // buffered input frames
+	inPos   float64   // nominal position of the next window in in
+	prevPos int       // position of the previous window in in, or -1
+	tail    []float32 // second half of the previous windowed frame
+}
+
+func newWSOLA(sampleRate float64, channels int) *wsola {
+	window := int(sampleRate*0.03) &^ 1 // 30 ms, even
+	window = max(window, 64)
+	w := &wsola{
+		channels:  channels,
+		window:    window,
+		hop:       window / 2,
+		tolerance: max(int(sampleRate*0.01), 8),
+		hann:      make([]float32, window),
+		prevPos:   -1,
+		tail:      make([]float32, window/2*channels),
+	}
+	for i := range w.hann {
+		// Periodic Hann windows at 50% overlap sum to one
+		w.hann[i] = float32(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(window)))
+	}
+	return w
+}
+
+// Process appends in to the buffer and emits one hop per window that can be
+// placed; tempo > 1 speeds up, tempo < 1 slows down
+func (w *wsola) Process(in []float32, tempo float64) []float32 {
+	w.in = append(w.in, in...)
+	frames := len(w.in) / w.channels
+
+	var out []float32
+	for {
+		nominal := int(w.inPos)
+		if nominal+w.tolerance+w.window > frames || (w.prevPos >= 0 && w.prevPos+w.hop+w.window > frames) {
+			break
+		}
+
+		pos := nominal
+		if w.prevPos >= 0 {
+			pos = w.bestMatch(nominal)
+		}
+
+		out = append(out, w.tail...)
+		base := len(out) - len(w.tail)
+		for i := 0; i < w.hop; i++ {
+			for ch := 0; ch < w.channels; ch++ {
+				out[base+i*w.channels+ch] += w.in[(pos+i)*w.channels+ch] * w.hann[i]
+			}
+		}
+		for i := 0; i < w.hop; i++ {
+			for ch := 0; ch < w.channels; ch++ {
+				w.tail[i*w.channels+ch] = w.in[(pos+w.hop+i)*w.channels+ch] * w.hann[w.hop+i]
+			}
+		}
+
+		w.prevPos = pos
+		w.inPos += float64(w.hop) * tempo
+	}
+
+	w.discard()
+	return out
+}
+
+// Flush emits the remaining input at the current tempo's position
+func (w *wsola) Flush() []float32 {
+	out := append([]float32(nil), w.tail...)
+	clear(w.tail)
+
+	start := int(w.inPos)
+	if w.prevPos >= 0 {
+		start = min(start, w.prevPos+w.hop)
+	}
+	start = max(start, 0) * w.channels
+	if start < len(w.in) {
+		rest := w.in[start:]
+		// The tail already holds the faded-out start of rest
+		skip := min(len(rest), w.hop*w.channels)
+		if w.prevPos >= 0 {
+			for i := 0; i < skip; i++ {
+				out[i] += rest[i] * w.hann[i/w.channels]
+			}
+			rest = rest[skip:]
+		}
+		out = append(out, rest...)
+	}
+
+	w.in = w.in[:0]
+	w.inPos = 0
+	w.prevPos = -1
+	return out
+}
+
+// bestMatch searches around nominal for the window most similar to the
+// audio that followed the previous window
+func (w *wsola) bestMatch(nominal int) int {
+	target := w.prevPos + w.hop
+	best, bestScore := nominal, math.Inf(-1)
+	for k := max(nominal-w.tolerance, 0); k <= nominal+w.tolerance; k++ {
+		var dot, energy float64
+		for i := 0; i < w.hop; i += 2 {
+			var a, b float32
+			for ch := 0; ch < w.channels; ch++ {
+				a += w.in[(target+i)*w.channels+ch]
+				b += w.in[(k+i)*w.channels+ch]
+			}
+			dot += float64(a * b)
+			energy += float64(b * b)
+		}
+		score := dot / math.Sqrt(energy+1e-9)
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// discard drops input no longer reachable by future windows
+func (w *wsola) discard() {
+	keep := int(w.inPos) - w.tolerance
+	if w.prevPos >= 0 {
+		keep = min(keep, w.prevPos+w.hop)
+	}
+	if keep <= 0 {
+		return
+	}
+	keep = min(keep, len(w.in)/w.channels)
+	w.in = append(w.in[:0], w.in[keep*w.channels:]...)
+	w.inPos -= float64(keep)
+	if w.prevPos >= 0 {
+		w.prevPos -= keep
+	}
+}
@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(freq, sampleRate float64, frames int) []float32 {
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/sampleRate))
+	}
+	return out
+}
+
+// processChunked runs in through a processor in decoder-sized chunks
+func processChunked(in []float32, sampleRate float64, fx Effects) []float32 {
+	proc := newProcessor(sampleRate, 1)
+	var out []float32
+	for start := 0; start < len(in); start += decodeChunkFrames {
+		end := min(start+decodeChunkFrames, len(in))
+		chunk := append([]float32(nil), in[start:end]...)
+		out = append(out, proc.Process(chunk, fx)...)
+	}
+	return append(out, proc.Flush(fx)...)
+}
+
+// zeroCrossings estimates frequency from upward zero crossings
+func zeroCrossings(samples []float32) int {
+	n := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestProcessorSpeedChangesLengthNotPitch(t *testing.T) {
+	const rate = 16000
+	in := sine(440, rate, rate*2)
+
+	for _, speed := range []float64{0.5, 1.5, 2} {
+		out := processChunked(in, rate, Effects{Speed: speed, Pitch: 1, Gain: 1})
+		want := float64(len(in)) / speed
+		if math.Abs(float64(len(out))-want) > want*0.05 {
+			t.Errorf("speed %.1f: got %d frames, want about %.0f", speed, len(out), want)
+		}
+
+		// The same tone over a different duration
+		gotHz := float64(zeroCrossings(out)) / (float64(len(out)) / rate)
+		if math.Abs(gotHz-440) > 20 {
+			t.Errorf("speed %.1f: tone is %.0f Hz, want 440 Hz", speed, gotHz)
+		}
+	}
+}
+
+func TestProcessorPitchKeepsLength(t *testing.T) {
+	const rate = 16000
+	in := sine(300, rate, rate*2)
+
+	out := processChunked(in, rate, Effects{Speed: 1, Pitch: 1.5, Gain: 1})
+	if math.Abs(float64(len(out)-len(in))) > float64(len(in))*0.05 {
+		t.Errorf("got %d frames, want about %d", len(out), len(in))
+	}
+	gotHz := float64(zeroCrossings(out)) / (float64(len(out)) / rate)
+	if math.Abs(gotHz-450) > 25 {
+		t.Errorf("tone is %.0f Hz, want 450 Hz", gotHz)
+	}
+}
+
+func TestProcessorNeutralIsPassthrough(t *testing.T) {
+	in := sine(440, 16000, 5000)
+	out := processChunked(in, 16000, NeutralEffects)
+	if len(out) != len(in) {
+		t.Fatalf("got %d frames, want %d", len(out), len(in))
+	}
+	for i := range in {
+		if out[i] != in[i] {
+			t.Fatalf("sample %d changed: %v != %v", i, out[i], in[i])
+		}
+	}
+
+	half := processChunked(in, 16000, Effects{Speed: 1, Pitch: 1, Gain: 0.5})
+	for i := range in {
+		if half[i] != in[i]*0.5 {
+			t.Fatalf("sample %d: got %v, want %v", i, half[i], in[i]*0.5)
+		}
+	}
+}
+
+func TestGainClipsToFullScale(t *testing.T) {
+	got := applyGain([]float32{0.5, -0.8, 0.1}, 2)
+	want := []float32{1, -1, 0.2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPlayerAppliesEffects(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	player.SetSpeed(2)
+
+	in := sine(440, 16000, 16000)
+	if err := player.PlayPCM(in, 16000, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := len(sink.Samples())
+	if math.Abs(float64(got)-8000) > 400 {
+		t.Errorf("played %d frames at double speed, want about 8000", got)
+	}
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac"
+)
+
+// flacDecoder streams samples from a FLAC file one frame at a time
+type flacDecoder struct {
+	stream  *flac.Stream
+	scale   float32
+	frame   []float32 // samples of the last parsed frame
+	pending []float32 // the part of frame not yet read
+}
+
+// newFLACDecoder reads the FLAC stream info and skips other metadata
+func newFLACDecoder(r io.Reader) (*flacDecoder, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FLAC header: %w", err)
+	}
+	return &flacDecoder{
+		stream: stream,
+		scale:  float32(int64(1) << (stream.Info.BitsPerSample - 1)),
+	}, nil
+}
+
+func (d *flacDecoder) SampleRate() float64 { return float64(d.stream.Info.SampleRate) }
+func (d *flacDecoder) Channels() int       { return int(d.stream.Info.NChannels) }
+
+func (d *flacDecoder) Read(dst []float32) (int, error) {
+	for len(d.pending) == 0 {
+		f, err := d.stream.ParseNext()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading FLAC frame: %w", err)
+		}
+		channels := d.Channels()
+		d.frame = d.frame[:0]
+		for i := 0; i < int(f.BlockSize); i++ {
+			for ch := 0; ch < channels; ch++ {
+				d.frame = append(d.frame, float32(f.Subframes[ch].Samples[i])/d.scale)
+			}
+		}
+		d.pending = d.frame
+	}
+	n := copy(dst, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+// audioFormat identifies an encoded audio container
+type audioFormat int
+
+const (
+	formatUnknown audioFormat = iota
+	formatWAV
+	formatMP3
+	formatOgg
+	formatFLAC
+)
+
+// detectFormat sniffs the container format from the first bytes of a stream
+func detectFormat(header []byte) audioFormat {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return formatWAV
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return formatOgg
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return formatFLAC
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return formatMP3
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// MPEG audio frame sync
+		return formatMP3
+	}
+	return formatUnknown
+}
+
+// Decode detects the format of the audio in r (WAV, MP3, FLAC or Ogg/Opus)
+// and returns interleaved float32 samples with the sample rate and channel count
+func Decode(r io.Reader) ([]float32, float64, int, error) {
+	return decodeAll(newDecoder(r))
+}
+
+// ConvertToWAV decodes audio in any supported format from r and writes it to
+// w as 16-bit PCM WAV
+func ConvertToWAV(w io.Writer, r io.Reader) error {
+	return ExportWAV(w, r, ExportOptions{})
+}
+
+// ExportOptions control how audio is processed when it is written to a file
+type ExportOptions struct {
+	SampleRate    int            // Resample to this rate (0 keeps the source rate)
+	Encoding      audio.Encoding // Sample format of the WAV data (16-bit PCM by default)
+	Normalization Normalization  // Loudness normalization over the whole recording
+}
+
+// ExportWAV decodes audio in any supported format from r, processes it as
+// configured by opts and writes it to w as WAV
+func ExportWAV(w io.Writer, r io.Reader, opts ExportOptions) error {
+	samples, sampleRate, channels, err := Decode(r)
+	if err != nil {
+		return err
+	}
+	return ExportPCM(w, samples, sampleRate, channels, opts)
+}
+
+// ExportPCM processes interleaved samples as configured by opts and writes
+// them to w as WAV. Normalization modifies samples in place.
+func ExportPCM(w io.Writer, samples []float32, sampleRate float64, channels int, opts ExportOptions) error {
+	if opts.Normalization.Enabled {
+		Normalize(samples, sampleRate, channels, opts.Normalization)
+	}
+	if opts.SampleRate > 0 && float64(opts.SampleRate) != sampleRate {
+		samples = audio.Resample(samples, channels, sampleRate, float64(opts.SampleRate))
+		sampleRate = float64(opts.SampleRate)
+	}
+	return audio.WriteWAV(w, samples, int(sampleRate), channels, opts.Encoding)
+}
@@ -0,0 +1,312 @@
+package pkg
+
+import (
+	"math"
+	"time"
+)
+
+// Normalization configures loudness normalization following EBU R128 /
+// ITU-R BS.1770: audio is measured with K-weighting and gating, then scaled
+// to TargetLUFS without letting its true peak exceed TruePeak.
+type Normalization struct {
+	Enabled    bool
+	TargetLUFS float64 // Integrated loudness target, e.g. -23 (broadcast) or -16 (speech)
+	TruePeak   float64 // Maximum true peak in dBTP, e.g. -1
+}
+
+// DefaultNormalization suits speech played on consumer devices
+var DefaultNormalization = Normalization{Enabled: true, TargetLUFS: -16, TruePeak: -1}
+
+const (
+	// loudnessLookahead is how much audio playback measures before fixing its
+	// gain; shorter utterances are measured completely
+	loudnessLookahead = 2 * time.Second
+
+	absoluteGate = -70.0 // LUFS
+	relativeGate = -10.0 // LU below the ungated loudness
+
+	truePeakOversample = 4
+	truePeakTaps       = 12 // input samples per interpolated sample
+)
+
+// biquad is a direct form II transposed second-order filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the BS.1770 pre-filter (a high shelf modelling the head)
+// and RLB high-pass filter for the given sample rate
+func kWeighting(sampleRate float64) (shelf, highPass biquad) {
+	k := math.Tan(math.Pi * 1681.974450955533 / sampleRate)
+	q := 0.7071752369554196
+	vh := math.Pow(10, 3.999843853973347/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf = biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * 38.13547087602444 / sampleRate)
+	q = 0.5003270373238773
+	a0 = 1 + k/q + k*k
+	highPass = biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+// channelWeight returns the BS.1770 weight of a channel: surround channels
+// count for more and the LFE channel of 5.1 audio is ignored
+func channelWeight(ch, channels int) float64 {
+	if channels == 6 {
+		return [6]float64{1, 1, 1, 0, 1.41, 1.41}[ch]
+	}
+	return 1
+}
+
+// loudnessMeter measures integrated loudness and true peak incrementally
+type loudnessMeter struct {
+	channels int
+	filters  [][2]biquad
+
+	subBlock    int       // frames per 100 ms
+	subFrames   int       // frames in the current sub-block
+	subPower    float64   // weighted energy of the current sub-block
+	recent      []float64 // mean power of the last sub-blocks, oldest first
+	blocks      []float64 // mean power of each 400 ms block
+	totalPower  float64   // energy of all audio, for clips shorter than a block
+	totalFrames int
+
+	peak    float64     // highest interpolated absolute sample
+	history [][]float64 // recent input per channel, for interpolation
+	kernel  [truePeakOversample][truePeakTaps]float64
+}
+
+func newLoudnessMeter(sampleRate float64, channels int) *loudnessMeter {
+	m := &loudnessMeter{
+		channels: channels,
+		filters:  make([][2]biquad, channels),
+		subBlock: max(int(sampleRate/10), 1),
+		history:  make([][]float64, channels),
+	}
+	for ch := range m.filters {
+		shelf, highPass := kWeighting(sampleRate)
+		m.filters[ch] = [2]biquad{shelf, highPass}
+		m.history[ch] = make([]float64, truePeakTaps)
+	}
+
+	// Hann-windowed sinc interpolation at each fractional phase
+	for p := 0; p < truePeakOversample; p++ {
+		frac := float64(p) / truePeakOversample
+		for i := 0; i < truePeakTaps; i++ {
+			t := float64(i-truePeakTaps/2+1) - frac
+			w := 0.5 + 0.5*math.Cos(math.Pi*t/float64(truePeakTaps/2))
+			m.kernel[p][i] = sinc(t) * w
+		}
+	}
+	return m
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// Add measures interleaved samples
+func (m *loudnessMeter) Add(samples []float32) {
+	for i := 0; i+m.channels <= len(samples); i += m.channels {
+		var power float64
+		for ch := 0; ch < m.channels; ch++ {
+			x := float64(samples[i+ch])
+			f := &m.filters[ch]
+			y := f[1].process(f[0].process(x))
+			power += channelWeight(ch, m.channels) * y * y
+
+			m.addPeak(ch, x)
+		}
+
+		m.subPower += power
+		m.totalPower += power
+		m.subFrames++
+		m.totalFrames++
+		if m.subFrames == m.subBlock {
+			m.endSubBlock()
+		}
+	}
+}
+
+// endSubBlock completes 100 ms of audio; blocks overlap by 75%, so each
+// sub-block completes a new 400 ms block
+func (m *loudnessMeter) endSubBlock() {
+	m.recent = append(m.recent, m.subPower/float64(m.subFrames))
+	if len(m.recent) > 4 {
+		m.recent = m.recent[1:]
+	}
+	if len(m.recent) == 4 {
+		m.blocks = append(m.blocks, (m.recent[0]+m.recent[1]+m.recent[2]+m.recent[3])/4)
+	}
+	m.subPower = 0
+	m.subFrames = 0
+}
+
+func (m *loudnessMeter) addPeak(ch int, x float64) {
+	h := m.history[ch]
+	copy(h, h[1:])
+	h[len(h)-1] = x
+
+	for p := 0; p < truePeakOversample; p++ {
+		var y float64
+		for i, c := range m.kernel[p] {
+			y += h[i] * c
+		}
+		m.peak = math.Max(m.peak, math.Abs(y))
+	}
+}
+
+// Loudness returns the gated integrated loudness in LUFS, or -Inf for silence
+func (m *loudnessMeter) Loudness() float64 {
+	if len(m.blocks) == 0 {
+		if m.totalFrames == 0 {
+			return math.Inf(-1)
+		}
+		return blockLoudness(m.totalPower / float64(m.totalFrames))
+	}
+
+	gated := func(threshold float64) float64 {
+		var sum float64
+		var n int
+		for _, p := range m.blocks {
+			if blockLoudness(p) > threshold {
+				sum += p
+				n++
+			}
+		}
+		if n == 0 {
+			return math.Inf(-1)
+		}
+		return blockLoudness(sum / float64(n))
+	}
+
+	ungated := gated(absoluteGate)
+	if math.IsInf(ungated, -1) {
+		return ungated
+	}
+	return gated(math.Max(absoluteGate, ungated+relativeGate))
+}
+
+// TruePeak returns the highest true peak in dBTP
+func (m *loudnessMeter) TruePeak() float64 {
+	return 20 * math.Log10(m.peak)
+}
+
+func blockLoudness(power float64) float64 {
+	return -0.691 + 10*math.Log10(power)
+}
+
+// normalizationGain returns the linear gain that brings audio measured by m
+// to the target loudness while keeping its true peak under the ceiling
+func normalizationGain(m *loudnessMeter, n Normalization) float64 {
+	loudness := m.Loudness()
+	if math.IsInf(loudness, -1) {
+		return 1
+	}
+	gainDB := n.TargetLUFS - loudness
+	if peak := m.TruePeak(); !math.IsInf(peak, -1) {
+		gainDB = math.Min(gainDB, n.TruePeak-peak)
+	}
+	return math.Pow(10, gainDB/20)
+}
+
+// MeasureLoudness returns the integrated loudness in LUFS and the true peak
+// in dBTP of interleaved samples
+func MeasureLoudness(samples []float32, sampleRate float64, channels int) (lufs, truePeak float64) {
+	m := newLoudnessMeter(sampleRate, channels)
+	m.Add(samples)
+	return m.Loudness(), m.TruePeak()
+}
+
+// Normalize scales interleaved samples in place to the target loudness,
+// limited by the true-peak ceiling, and returns the gain applied
+func Normalize(samples []float32, sampleRate float64, channels int, n Normalization) float64 {
+	m := newLoudnessMeter(sampleRate, channels)
+	m.Add(samples)
+	gain := normalizationGain(m, n)
+	applyGain(samples, gain)
+	return gain
+}
+
+// normalizer normalizes a stream. Integrated loudness needs the whole
+// programme, so it holds back the first loudnessLookahead of audio, fixes its
+// gain from that, and clamps any later peaks to the ceiling.
+type normalizer struct {
+	cfg       Normalization
+	meter     *loudnessMeter
+	lookahead int // samples to measure before playing
+	pending   []float32
+	gain      float64
+	ceiling   float32
+	locked    bool
+}
+
+func newNormalizer(sampleRate float64, channels int, n Normalization) *normalizer {
+	return &normalizer{
+		cfg:       n,
+		meter:     newLoudnessMeter(sampleRate, channels),
+		lookahead: int(sampleRate*loudnessLookahead.Seconds()) * channels,
+		ceiling:   float32(math.Pow(10, n.TruePeak/20)),
+	}
+}
+
+// Process returns normalized audio once the gain is known, buffering until then
+func (n *normalizer) Process(in []float32) []float32 {
+	if n.locked {
+		return n.apply(in)
+	}
+	n.pending = append(n.pending, in...)
+	n.meter.Add(in)
+	if len(n.pending) < n.lookahead {
+		return nil
+	}
+	return n.lock()
+}
+
+// Flush returns buffered audio at the end of a stream shorter than the lookahead
+func (n *normalizer) Flush() []float32 {
+	if n.locked {
+		return nil
+	}
+	return n.lock()
+}
+
+func (n *normalizer) lock() []float32 {
+	n.gain = normalizationGain(n.meter, n.cfg)
+	n.locked = true
+	out := n.apply(n.pending)
+	n.pending = nil
+	return out
+}
+
+func (n *normalizer) apply(samples []float32) []float32 {
+	g := float32(n.gain)
+	for i, s := range samples {
+		s *= g
+		samples[i] = max(-n.ceiling, min(n.ceiling, s))
+	}
+	return samples
+}
@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+func sineAt(amplitude, freq, phase, sampleRate float64, frames int) []float32 {
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = float32(amplitude * math.Sin(2*math.Pi*freq*float64(i)/sampleRate+phase))
+	}
+	return out
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	// BS.1770 calibration: a 997 Hz sine at -20 dBFS measures -23 LUFS on one channel
+	samples := sineAt(0.1, 997, 0, 48000, 48000*3)
+	lufs, _ := MeasureLoudness(samples, 48000, 1)
+	if math.Abs(lufs-(-23.01)) > 0.1 {
+		t.Errorf("got %.2f LUFS, want -23.01", lufs)
+	}
+
+	// The same tone on both stereo channels is 3 LU louder
+	stereo := make([]float32, len(samples)*2)
+	for i, s := range samples {
+		stereo[2*i], stereo[2*i+1] = s, s
+	}
+	lufs, _ = MeasureLoudness(stereo, 48000, 2)
+	if math.Abs(lufs-(-20)) > 0.1 {
+		t.Errorf("stereo: got %.2f LUFS, want -20", lufs)
+	}
+
+	lufs, _ = MeasureLoudness(make([]float32, 48000), 48000, 1)
+	if !math.IsInf(lufs, -1) {
+		t.Errorf("silence: got %.2f LUFS, want -Inf", lufs)
+	}
+}
+
+func TestTruePeakFindsInterSamplePeaks(t *testing.T) {
+	// A quarter-rate sine sampled 45 degrees off its peaks never has a
+	// sample above 0.707, but its true peak is 1
+	samples := sineAt(1, 12000, math.Pi/4, 48000, 4800)
+	_, peak := MeasureLoudness(samples, 48000, 1)
+	if peak < -0.5 {
+		t.Errorf("got true peak %.2f dBTP, want about 0", peak)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	n := Normalization{Enabled: true, TargetLUFS: -16, TruePeak: -1}
+
+	quiet := sineAt(0.05, 440, 0, 48000, 48000*2)
+	Normalize(quiet, 48000, 1, n)
+	if lufs, _ := MeasureLoudness(quiet, 48000, 1); math.Abs(lufs-(-16)) > 0.2 {
+		t.Errorf("got %.2f LUFS, want -16", lufs)
+	}
+
+	// Reaching -5 LUFS would need a true peak above the ceiling
+	loud := Normalization{Enabled: true, TargetLUFS: -5, TruePeak: -1}
+	samples := sineAt(0.05, 440, 0, 48000, 48000*2)
+	Normalize(samples, 48000, 1, loud)
+	if _, peak := MeasureLoudness(samples, 48000, 1); peak > -0.9 {
+		t.Errorf("got true peak %.2f dBTP, want at most -1", peak)
+	}
+}
+
+func TestPlayerNormalizesLoudness(t *testing.T) {
+	for _, frames := range []int{16000, 16000 * 4} {
+		sink := NewMemorySink()
+		player := NewAudioPlayerWithSink(sink)
+		player.SetNormalization(DefaultNormalization)
+
+		if err := player.PlayPCM(sineAt(0.02, 440, 0, 16000, frames), 16000, 1); err != nil {
+			t.Fatal(err)
+		}
+		if err := player.WaitForCompletion(); err != nil {
+			t.Fatal(err)
+		}
+
+		out := sink.Samples()
+		if len(out) != frames {
+			t.Fatalf("played %d frames, want %d", len(out), frames)
+		}
+		if lufs, _ := MeasureLoudness(out, 16000, 1); math.Abs(lufs-(-16)) > 0.5 {
+			t.Errorf("%d frames: got %.2f LUFS, want -16", frames, lufs)
+		}
+	}
+}
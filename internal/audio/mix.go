@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"math"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+// ChannelLimiter is implemented by sinks whose output device supports a
+// limited number of channels. The player mixes audio down to that count.
+type ChannelLimiter interface {
+	MaxChannels() (int, error)
+}
+
+// outputChannels picks the channel count to open the sink with: the source
+// count (at least stereo when panning), capped by what the device supports
+func outputChannels(sink AudioSink, channels int, pan float64) int {
+	out := channels
+	if pan != 0 && out < 2 {
+		out = 2
+	}
+	if limiter, ok := sink.(ChannelLimiter); ok {
+		if max, err := limiter.MaxChannels(); err == nil && max > 0 && out > max {
+			out = max
+		}
+	}
+	return out
+}
+
+// RateNegotiator is implemented by sinks whose output device accepts only
+// some sample rates. The player resamples audio to the rate it returns.
+type RateNegotiator interface {
+	SupportedRate(sampleRate float64) (float64, error)
+}
+
+// outputRate picks the sample rate to open the sink with: the player's fixed
+// output rate if one is set, otherwise the source rate, either way replaced
+// by a rate the device supports
+func outputRate(sink AudioSink, sampleRate, fixed float64) float64 {
+	rate := sampleRate
+	if fixed > 0 {
+		rate = fixed
+	}
+	if negotiator, ok := sink.(RateNegotiator); ok {
+		if supported, err := negotiator.SupportedRate(rate); err == nil && supported > 0 {
+			rate = supported
+		}
+	}
+	return rate
+}
+
+// remix converts interleaved samples from one channel count to another
+func remix(samples []float32, from, to int) []float32 {
+	return audio.Remix(samples, from, to)
+}
+
+// applyPan positions interleaved audio between the left (-1) and right (+1)
+// channels using a balance law, so centred audio keeps its level. Only the
+// first two channels are affected.
+func applyPan(samples []float32, channels int, pan float64) {
+	if channels < 2 || pan == 0 {
+		return
+	}
+	pan = math.Max(-1, math.Min(1, pan))
+	left := float32(math.Min(1, 1-pan))
+	right := float32(math.Min(1, 1+pan))
+	for i := 0; i+1 < len(samples); i += channels {
+		samples[i] *= left
+		samples[i+1] *= right
+	}
+}
@@ -0,0 +1,278 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+type limitedSink struct {
+	*MemorySink
+	max int
+}
+
+func (s limitedSink) MaxChannels() (int, error) { return s.max, nil }
+
+func TestRemix(t *testing.T) {
+	testCases := []struct {
+		name     string
+		samples  []float32
+		from, to int
+		want     []float32
+	}{
+		{"mono to stereo", []float32{0.1, 0.2}, 1, 2, []float32{0.1, 0.1, 0.2, 0.2}},
+		{"stereo to mono", []float32{0.2, 0.4, -0.2, 0}, 2, 1, []float32{0.3, -0.1}},
+		{"stereo to quad", []float32{0.1, 0.2}, 2, 4, []float32{0.1, 0.2, 0, 0}},
+		{"quad to stereo", []float32{0.1, 0.2, 0.3, 0.4}, 4, 2, []float32{0.1, 0.2}},
+		{"unchanged", []float32{0.5}, 1, 1, []float32{0.5}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := remix(tc.samples, tc.from, tc.to)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-tc.want[i])) > 1e-6 {
+					t.Fatalf("got %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRemixSurroundToStereo(t *testing.T) {
+	// Centre-only 5.1 audio should land equally in both channels
+	got := remix([]float32{0, 0, 1, 1, 0, 0}, 6, 2)
+	if got[0] != got[1] || got[0] <= 0 || got[0] > 1 {
+		t.Errorf("Unexpected downmix of centre channel: %v", got)
+	}
+}
+
+func TestPlayMixesToDeviceChannels(t *testing.T) {
+	sink := limitedSink{NewMemorySink(), 1}
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	if err := player.PlayPCM([]float32{0.2, 0.4, 0.6, 0.8}, 16000, 2); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	player.WaitForCompletion()
+
+	if sink.Channels() != 1 {
+		t.Errorf("Expected sink opened with 1 channel, got %d", sink.Channels())
+	}
+	want := []float32{0.3, 0.7}
+	got := sink.Samples()
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPlayPansMonoToStereo(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	samples := []float32{0.5, 1}
+	player.SetPan(-0.5)
+	if err := player.PlayPCM(samples, 16000, 1); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	player.WaitForCompletion()
+
+	if sink.Channels() != 2 {
+		t.Fatalf("Expected panned mono to open 2 channels, got %d", sink.Channels())
+	}
+	if got, want := sink.Samples(), []float32{0.5, 0.25, 1, 0.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(samples, []float32{0.5, 1}) {
+		t.Error("Expected caller's samples to be left unchanged")
+	}
+
+	sink.Reset()
+	player.SetPan(0)
+	if err := player.PlayPCM(samples, 16000, 1); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	player.WaitForCompletion()
+	if sink.Channels() != 1 {
+		t.Errorf("Expected centred mono to stay mono, got %d channels", sink.Channels())
+	}
+}
+
+type fixedRateSink struct {
+	*MemorySink
+	rate float64
+}
+
+func (s fixedRateSink) SupportedRate(sampleRate float64) (float64, error) { return s.rate, nil }
+
+func TestPlayResamplesToDeviceRate(t *testing.T) {
+	sink := fixedRateSink{NewMemorySink(), 48000}
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	if err := player.PlayPCM(make([]float32, 22050), 22050, 1); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sink.SampleRate() != 48000 {
+		t.Errorf("Expected sink opened at 48000 Hz, got %v", sink.SampleRate())
+	}
+	if got := len(sink.Samples()); got != 48000 {
+		t.Errorf("Expected one second at 48000 Hz, got %d frames", got)
+	}
+}
+
+func TestPlayAtFixedOutputRate(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+	player.SetOutputSampleRate(8000)
+
+	if err := player.PlayPCM(make([]float32, 2*24000), 24000, 2); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sink.SampleRate() != 8000 {
+		t.Errorf("Expected sink opened at 8000 Hz, got %v", sink.SampleRate())
+	}
+	if got := len(sink.Samples()); got != 2*8000 {
+		t.Errorf("Expected one second of stereo at 8000 Hz, got %d samples", got)
+	}
+}
+
+func TestPlayInsertsGapAndTrimsSilence(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+	player.SetGap(100 * time.Millisecond)
+	player.SetTrimSilence(&audio.TrimOptions{Threshold: -40, Window: 10 * time.Millisecond})
+
+	clip := append(make([]float32, 4000), 0.5, -0.5, 0.5, -0.5)
+	clip = append(clip, make([]float32, 4000)...)
+	var wav bytes.Buffer
+	writeWAVHeader(&wav, 16000, 1, uint32(len(clip)*2))
+	wav.Write(floatToPCM16(clip))
+
+	// Keep the first clip open so the second is queued behind it
+	pr, pw := io.Pipe()
+	written := make(chan struct{})
+	go func() {
+		pw.Write(wav.Bytes())
+		close(written)
+	}()
+	if err := player.Enqueue(pr); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := player.Enqueue(bytes.NewReader(wav.Bytes())); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	<-written
+	pw.Close()
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each clip is trimmed to its loud 10 ms window; only the queued one gets the gap
+	if got, want := len(sink.Samples()), 160+1600+160; got != want {
+		t.Errorf("Expected %d frames, got %d", want, got)
+	}
+
+	// Once the queue has drained, the next utterance starts without a gap
+	if err := player.PlayPCM(clip, 16000, 1); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(sink.Samples()), 160+1600+160+160; got != want {
+		t.Errorf("Expected %d frames, got %d", want, got)
+	}
+}
+
+// positionSink reports a fixed number of played frames
+type positionSink struct {
+	*MemorySink
+	played int64
+}
+
+func (s *positionSink) FramesPlayed() int64 { return s.played }
+
+func TestSourcePositionExcludesGapTrimAndSpeed(t *testing.T) {
+	sink := &positionSink{MemorySink: NewMemorySink(), played: math.MaxInt64}
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+	player.SetGap(100 * time.Millisecond)
+	player.SetTrimSilence(&audio.TrimOptions{Threshold: -40, Window: 10 * time.Millisecond})
+	player.SetSpeed(1.5)
+
+	// Half a second of silence, a second of tone, then a quarter second of silence
+	clip := append(make([]float32, 8000), sine(440, 16000, 16000)...)
+	clip = append(clip, make([]float32, 4000)...)
+	var wav bytes.Buffer
+	writeWAVHeader(&wav, 16000, 1, uint32(len(clip)*2))
+	wav.Write(floatToPCM16(clip))
+
+	// Keep the first utterance open so the clip is queued behind it with the gap
+	pr, pw := io.Pipe()
+	written := make(chan struct{})
+	go func() {
+		pw.Write(wav.Bytes()[:44+1600*2])
+		close(written)
+	}()
+	if err := player.Enqueue(pr); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := player.Enqueue(bytes.NewReader(wav.Bytes())); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	<-written
+	pw.Close()
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatal(err)
+	}
+
+	near := func(got, want time.Duration) bool {
+		return (got - want).Abs() <= 50*time.Millisecond
+	}
+
+	// The first utterance is all silence and is trimmed away entirely
+	first := int64(len(sink.Samples())) - player.written
+	gap := int64(1600)
+
+	testCases := []struct {
+		name   string
+		played int64
+		want   time.Duration
+	}{
+		{"during gap", first + gap/2, 0},
+		{"sound starts", first + gap, 500 * time.Millisecond},
+		{"mid tone", first + gap + 16000/3, time.Second},
+		{"finished", math.MaxInt64, 1500 * time.Millisecond},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sink.played = tc.played
+			if got := player.SourcePosition(); !near(got, tc.want) {
+				t.Errorf("Expected source position %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// opusSampleRate is the rate libopus decodes at, regardless of the input rate
+// recorded in the OpusHead packet
+const opusSampleRate = 48000
+
+// opusMaxFrameSize is the largest Opus packet duration (120 ms) in samples
+const opusMaxFrameSize = opusSampleRate * 120 / 1000
+
+// errOpusUnsupported is returned when the package was built without libopus
+var errOpusUnsupported = errors.New("Opus decoding requires building with the opus tag")
+
+// OpusSupported reports whether Ogg/Opus audio can be decoded. It is false
+// unless the package was built with the opus tag and linked with libopus.
+func OpusSupported() bool {
+	return opusSupported
+}
+
+// opusPacketDecoder decodes single Opus packets to interleaved float32
+// samples at 48 kHz, returning the number of samples per channel
+type opusPacketDecoder interface {
+	DecodeFloat32(packet []byte, pcm []float32) (int, error)
+}
+
+var oggCRCTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggReader splits the pages of the first logical bitstream in an Ogg
+// container into packets
+type oggReader struct {
+	r        io.Reader
+	serial   uint32
+	started  bool
+	pending  [][]byte // complete packets not yet returned
+	partial  []byte   // packet continued on the next page
+	granule  int64    // granule position of the last page read
+	finished bool
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: r}
+}
+
+// NextPacket returns the next packet, or io.EOF after the last one
+func (o *oggReader) NextPacket() ([]byte, error) {
+	for len(o.pending) == 0 {
+		if o.finished {
+			return nil, io.EOF
+		}
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := o.pending[0]
+	o.pending = o.pending[1:]
+	return packet, nil
+}
+
+func (o *oggReader) readPage() error {
+	var header [27]byte
+	if _, err := io.ReadFull(o.r, header[:]); err != nil {
+		if err == io.EOF && o.started {
+			o.finished = true
+			return nil
+		}
+		return fmt.Errorf("failed to read Ogg page: %w", err)
+	}
+	if string(header[0:4]) != "OggS" {
+		return fmt.Errorf("invalid Ogg page signature")
+	}
+
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, segments); err != nil {
+		return fmt.Errorf("failed to read Ogg segment table: %w", err)
+	}
+	size := 0
+	for _, s := range segments {
+		size += int(s)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(o.r, body); err != nil {
+		return fmt.Errorf("failed to read Ogg page body: %w", err)
+	}
+
+	want := binary.LittleEndian.Uint32(header[22:26])
+	binary.LittleEndian.PutUint32(header[22:26], 0)
+	if crc := oggCRC(oggCRC(oggCRC(0, header[:]), segments), body); crc != want {
+		return fmt.Errorf("Ogg page checksum mismatch")
+	}
+
+	serial := binary.LittleEndian.Uint32(header[14:18])
+	if !o.started {
+		o.serial = serial
+		o.started = true
+	} else if serial != o.serial {
+		// Ignore multiplexed streams other than the first
+		return nil
+	}
+	if header[5]&0x01 == 0 {
+		// A fresh packet starts on this page
+		o.partial = nil
+	}
+
+	for _, s := range segments {
+		o.partial = append(o.partial, body[:s]...)
+		body = body[s:]
+		if s < 255 {
+			o.pending = append(o.pending, o.partial)
+			o.partial = nil
+		}
+	}
+
+	o.granule = int64(binary.LittleEndian.Uint64(header[6:14]))
+	if header[5]&0x04 != 0 {
+		o.finished = true
+	}
+	return nil
+}
+
+// opusHead is the identification header of an Ogg/Opus stream (RFC 7845)
+type opusHead struct {
+	channels      int
+	preSkip       int
+	outputGain    float64 // dB
+	mappingFamily int
+}
+
+func parseOpusHead(packet []byte) (opusHead, error) {
+	if len(packet) < 19 || !bytes.HasPrefix(packet, []byte("OpusHead")) {
+		return opusHead{}, fmt.Errorf("missing OpusHead packet")
+	}
+	if packet[8]>>4 != 0 {
+		return opusHead{}, fmt.Errorf("unsupported Ogg/Opus version %d", packet[8])
+	}
+	head := opusHead{
+		channels:      int(packet[9]),
+		preSkip:       int(binary.LittleEndian.Uint16(packet[10:12])),
+		outputGain:    float64(int16(binary.LittleEndian.Uint16(packet[16:18]))) / 256,
+		mappingFamily: int(packet[18]),
+	}
+	if head.channels == 0 {
+		return opusHead{}, fmt.Errorf("invalid Opus channel count 0")
+	}
+	if head.mappingFamily != 0 || head.channels > 2 {
+		return opusHead{}, fmt.Errorf("unsupported Opus channel mapping family %d with %d channels",
+			head.mappingFamily, head.channels)
+	}
+	return head, nil
+}
+
+// oggOpusDecoder streams samples from an Ogg/Opus file at 48 kHz, applying
+// the pre-skip, end trimming and output gain
+type oggOpusDecoder struct {
+	ogg      *oggReader
+	head     opusHead
+	decoder  opusPacketDecoder
+	gain     float32
+	pcm      []float32
+	pending  []float32
+	position int64 // samples per channel decoded so far, including pre-skip
+}
+
+// newOggOpusDecoder reads the OpusHead and OpusTags packets
+func newOggOpusDecoder(r io.Reader) (*oggOpusDecoder, error) {
+	ogg := newOggReader(r)
+
+	packet, err := ogg.NextPacket()
+	if err != nil {
+		return nil, err
+	}
+	head, err := parseOpusHead(packet)
+	if err != nil {
+		return nil, err
+	}
+	if packet, err = ogg.NextPacket(); err != nil || !bytes.HasPrefix(packet, []byte("OpusTags")) {
+		return nil, fmt.Errorf("missing OpusTags packet")
+	}
+
+	decoder, err := newOpusDecoder(opusSampleRate, head.channels)
+	if err != nil {
+		return nil, err
+	}
+	return &oggOpusDecoder{
+		ogg:     ogg,
+		head:    head,
+		decoder: decoder,
+		gain:    float32(math.Pow(10, head.outputGain/20)),
+		pcm:     make([]float32, opusMaxFrameSize*head.channels),
+	}, nil
+}
+
+func (d *oggOpusDecoder) SampleRate() float64 { return opusSampleRate }
+func (d *oggOpusDecoder) Channels() int       { return d.head.channels }
+
+func (d *oggOpusDecoder) Read(dst []float32) (int, error) {
+	for len(d.pending) == 0 {
+		packet, err := d.ogg.NextPacket()
+		if err != nil {
+			return 0, err
+		}
+		n, err := d.decoder.DecodeFloat32(packet, d.pcm)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decode Opus packet: %w", err)
+		}
+
+		first := d.position
+		d.position += int64(n)
+
+		// The first preSkip samples are decoder warm-up, and the granule
+		// position of the last page marks the end of the audio
+		start, end := max(first, int64(d.head.preSkip)), d.position
+		if d.ogg.finished && d.ogg.granule > 0 {
+			end = min(end, d.ogg.granule)
+		}
+		if start >= end {
+			continue
+		}
+
+		ch := int64(d.head.channels)
+		d.pending = d.pcm[(start-first)*ch : (end-first)*ch]
+		if d.gain != 1 {
+			for i := range d.pending {
+				d.pending[i] *= d.gain
+			}
+		}
+	}
+	n := copy(dst, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
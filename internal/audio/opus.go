@@ -0,0 +1,18 @@
+//go:build opus
+
+package pkg
+
+import "gopkg.in/hraban/opus.v2"
+
+// opusSupported is true when built with libopus
+const opusSupported = true
+
+// newOpusDecoder creates a libopus packet decoder. Build with the opus tag
+// (and nolibopusfile if libopusfile is not installed) to enable it.
+var newOpusDecoder = func(sampleRate, channels int) (opusPacketDecoder, error) {
+	dec, err := opus.NewDecoder(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	return dec, nil
+}
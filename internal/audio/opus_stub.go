@@ -0,0 +1,13 @@
+//go:build !opus
+
+package pkg
+
+// opusSupported is false in default builds, which need no cgo; providers
+// refuse to request Opus audio that could not be played
+const opusSupported = false
+
+// newOpusDecoder reports that Opus is unavailable; build with the opus tag
+// to decode Opus packets with libopus
+var newOpusDecoder = func(sampleRate, channels int) (opusPacketDecoder, error) {
+	return nil, errOpusUnsupported
+}
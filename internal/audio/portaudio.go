@@ -0,0 +1,495 @@
+package pkg
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+const (
+	// framesPerBuffer is the number of frames PortAudio requests per callback
+	framesPerBuffer = 512
+	// prefillBuffers is how many callback buffers are queued before the
+	// device starts, so playback begins after the first few decoded frames
+	prefillBuffers = 4
+	// bufferSeconds bounds the audio queued ahead of the device
+	bufferSeconds = 0.5
+	// idleRelease is how long a sink stays idle before releasing PortAudio,
+	// which lets PortAudio pick up added or removed devices
+	idleRelease = 5 * time.Second
+)
+
+// PortAudioSink plays audio on a PortAudio output device, the default one
+// unless another is chosen with SetDevice.
+// Written audio is queued in a fixed-size ring buffer that a PortAudio
+// callback drains without taking a lock, so memory use does not grow with
+// the utterance length and the callback never waits for a writer.
+// PortAudio is initialized and the device opened on the first Open, so
+// creating the sink never touches the sound card.
+type PortAudioSink struct {
+	streamMu    sync.Mutex // serializes stream start/stop and device queries; never held by the callback
+	mu          sync.Mutex // guards the fields below; never taken by the callback
+	cond        *sync.Cond
+	initialized bool
+	deviceID    string // requested device; empty for the default
+	activeID    string // device the current stream was opened on
+	reopen      bool   // the device changed since the stream was opened
+	stream      *portaudio.Stream
+	ring        *ringBuffer
+	sampleRate  float64
+	channels    int
+	running     bool
+	paused      atomic.Bool  // read by the callback; set with mu held
+	stopped     atomic.Bool  // read by the callback; set with mu held
+	played      atomic.Int64 // frames the callback has handed to the device
+	idleTimer   *time.Timer
+	idleGen     int
+}
+
+// NewPortAudioSink creates a sink for the default output device
+func NewPortAudioSink() *PortAudioSink {
+	return NewPortAudioSinkForDevice("")
+}
+
+// NewPortAudioSinkForDevice creates a sink for the output device with the
+// given ID, as returned by DeviceIDs
+func NewPortAudioSinkForDevice(deviceID string) *PortAudioSink {
+	s := &PortAudioSink{deviceID: deviceID}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// SetDevice selects the output device for the next utterance. An empty ID
+// selects the default device.
+func (s *PortAudioSink) SetDevice(deviceID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if deviceID != s.deviceID {
+		s.deviceID = deviceID
+		s.reopen = true
+	}
+}
+
+// ActiveDevice returns the ID of the device the sink last opened, which
+// differs from the requested one after falling back to the default
+func (s *PortAudioSink) ActiveDevice() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.activeID
+}
+
+// MaxChannels initializes PortAudio if needed and returns the number of
+// output channels of the selected device
+func (s *PortAudioSink) MaxChannels() (int, error) {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	device, err := s.selectedDevice()
+	if err != nil {
+		return 0, err
+	}
+	return device.MaxOutputChannels, nil
+}
+
+// SupportedRate returns sampleRate if the selected device accepts it, or
+// the device's default rate otherwise
+func (s *PortAudioSink) SupportedRate(sampleRate float64) (float64, error) {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	s.mu.Lock()
+	current := s.stream != nil && !s.reopen && s.sampleRate == sampleRate
+	s.mu.Unlock()
+	if current {
+		return sampleRate, nil
+	}
+
+	device, err := s.selectedDevice()
+	if err != nil {
+		return 0, err
+	}
+	params := portaudio.HighLatencyParameters(nil, device)
+	params.Output.Channels = min(device.MaxOutputChannels, 2)
+	params.SampleRate = sampleRate
+	// Only the callback's sample format matters here
+	if portaudio.IsFormatSupported(params, func([]float32) {}) == nil {
+		return sampleRate, nil
+	}
+	return device.DefaultSampleRate, nil
+}
+
+// selectedDevice initializes PortAudio if needed and resolves the selected
+// device. PortAudio is queried without holding mu, so the callback is never
+// kept waiting. The caller must hold streamMu, which keeps PortAudio from
+// being terminated during the query.
+func (s *PortAudioSink) selectedDevice() (*portaudio.DeviceInfo, error) {
+	s.mu.Lock()
+	err := s.initLocked()
+	id := s.deviceID
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	device, _, err := resolveDevice(id)
+	return device, err
+}
+
+// resolveDevice returns the requested device, or the default output device
+// if none was requested or the requested one has disappeared
+func resolveDevice(id string) (*portaudio.DeviceInfo, string, error) {
+	if id == "" {
+		id = DefaultDevice()
+	}
+	if id != "" {
+		if device, resolved, err := findOutputDevice(id); err == nil {
+			return device, resolved, nil
+		}
+	}
+	return defaultOutputDevice()
+}
+
+// defaultOutputDevice returns PortAudio's default output device and its ID
+func defaultOutputDevice() (*portaudio.DeviceInfo, string, error) {
+	device, err := portaudio.DefaultOutputDevice()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get default output device: %w", err)
+	}
+	devices, err := portaudio.Devices()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get devices: %w", err)
+	}
+	ids := DeviceIDs(devices)
+	for i, dev := range devices {
+		if dev == device {
+			return device, ids[i], nil
+		}
+	}
+	return device, "", nil
+}
+
+func (s *PortAudioSink) initLocked() error {
+	if s.initialized {
+		return nil
+	}
+	if err := portaudio.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize PortAudio: %w", err)
+	}
+	s.initialized = true
+	return nil
+}
+
+// Open initializes PortAudio if needed and opens an output stream in the
+// given format, reusing the current stream when the format is unchanged
+func (s *PortAudioSink) Open(sampleRate float64, channels int) error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	s.mu.Lock()
+	s.cancelIdleLocked()
+	if err := s.initLocked(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.stopped.Store(false)
+	s.paused.Store(false)
+	s.played.Store(0)
+	// A stream is only reused once it has been stopped, so the callback
+	// is not reading the ring
+	if s.ring != nil {
+		s.ring.Reset()
+	}
+	reuse := s.stream != nil && !s.reopen && s.sampleRate == sampleRate && s.channels == channels
+	requested := s.deviceID
+	s.mu.Unlock()
+
+	if reuse {
+		return nil
+	}
+	device, deviceID, err := resolveDevice(requested)
+	if err != nil {
+		return err
+	}
+	s.closeStream()
+
+	ring := newRingBuffer(max(int(sampleRate*bufferSeconds), 2*prefillBuffers*framesPerBuffer) * channels)
+	stream, err := s.openStream(device, sampleRate, channels, ring)
+	if err != nil {
+		// The chosen device may have been unplugged; try the default
+		fallback, fallbackID, defaultErr := defaultOutputDevice()
+		if defaultErr != nil || fallback == device {
+			return fmt.Errorf("failed to open audio stream: %w", err)
+		}
+		if stream, err = s.openStream(fallback, sampleRate, channels, ring); err != nil {
+			return fmt.Errorf("failed to open audio stream: %w", err)
+		}
+		deviceID = fallbackID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stream = stream
+	s.activeID = deviceID
+	s.reopen = false
+	s.sampleRate = sampleRate
+	s.channels = channels
+	s.ring = ring
+	return nil
+}
+
+// openStream opens a stream whose callback plays from ring. The callback
+// holds its own references, so it never reads fields guarded by mu.
+func (s *PortAudioSink) openStream(device *portaudio.DeviceInfo, sampleRate float64, channels int, ring *ringBuffer) (*portaudio.Stream, error) {
+	params := portaudio.HighLatencyParameters(nil, device)
+	params.Output.Channels = channels
+	params.SampleRate = sampleRate
+	params.FramesPerBuffer = framesPerBuffer
+	return portaudio.OpenStream(params, func(out []float32) {
+		s.callback(ring, channels, out)
+	})
+}
+
+// callback is invoked by PortAudio on its audio thread to fill out. It
+// must never block, so it reads the ring and the flags without taking mu.
+// Writers waiting for space may miss a wakeup between checking the ring and
+// waiting; the next callback wakes them again.
+func (s *PortAudioSink) callback(ring *ringBuffer, channels int, out []float32) {
+	n := 0
+	if !s.paused.Load() && !s.stopped.Load() {
+		n = ring.Read(out)
+	}
+	clear(out[n:])
+	s.played.Add(int64(n / channels))
+	s.cond.Broadcast()
+}
+
+// Write queues samples, blocking while the buffer is full. The device is
+// started once enough audio is queued to ride out decoding hiccups.
+func (s *PortAudioSink) Write(samples []float32) error {
+	for len(samples) > 0 {
+		s.mu.Lock()
+		for !s.stopped.Load() && s.stream != nil && s.running && s.ring.Free() == 0 {
+			s.cond.Wait()
+		}
+		if s.stopped.Load() || s.stream == nil {
+			s.mu.Unlock()
+			return ErrSinkStopped
+		}
+		n := s.ring.Write(samples)
+		samples = samples[n:]
+		start := !s.running && (s.ring.Len() >= prefillBuffers*framesPerBuffer*s.channels || s.ring.Free() == 0)
+		s.mu.Unlock()
+
+		if start {
+			if err := s.start(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Pause silences the device and blocks writers once the buffer fills
+func (s *PortAudioSink) Pause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused.Store(true)
+	return nil
+}
+
+// Resume continues playback from the buffered audio
+func (s *PortAudioSink) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused.Store(false)
+	s.cond.Broadcast()
+	return nil
+}
+
+// Stop aborts the device, discarding buffered audio
+func (s *PortAudioSink) Stop() error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	// The callback plays silence from here on
+	s.mu.Lock()
+	s.stopped.Store(true)
+	s.paused.Store(false)
+	running, stream := s.running, s.stream
+	s.running = false
+	s.scheduleIdleLocked()
+	s.mu.Unlock()
+	s.cond.Broadcast()
+
+	if running {
+		if err := stream.Abort(); err != nil {
+			return fmt.Errorf("failed to stop audio: %w", err)
+		}
+	}
+
+	// With the stream aborted the callback no longer reads the ring
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ring != nil {
+		s.ring.Reset()
+	}
+	return nil
+}
+
+// Drain starts the device if the utterance was shorter than the prefill,
+// waits for the buffer to empty and stops the device once it has played
+func (s *PortAudioSink) Drain() error {
+	s.mu.Lock()
+	if s.stream == nil || s.stopped.Load() {
+		s.mu.Unlock()
+		return nil
+	}
+	pending := s.ring.Len() > 0 && !s.running
+	s.mu.Unlock()
+
+	if pending {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	for !s.stopped.Load() && s.running && s.ring.Len() > 0 {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	s.mu.Lock()
+	running, stopped, stream := s.running, s.stopped.Load(), s.stream
+	s.running = false
+	s.scheduleIdleLocked()
+	s.mu.Unlock()
+
+	// Stopping (rather than aborting) plays out the buffers already
+	// handed to the device
+	if running && !stopped {
+		if err := stream.Stop(); err != nil {
+			return fmt.Errorf("failed to drain audio: %w", err)
+		}
+	}
+	return nil
+}
+
+// FramesPlayed returns the number of frames of the current utterance that
+// have been handed to the device
+func (s *PortAudioSink) FramesPlayed() int64 {
+	return s.played.Load()
+}
+
+// Close releases the device and PortAudio
+func (s *PortAudioSink) Close() error {
+	s.Stop()
+
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+	s.closeStream()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelIdleLocked()
+	if s.initialized {
+		s.initialized = false
+		return portaudio.Terminate()
+	}
+	return nil
+}
+
+// scheduleIdleLocked arranges for the stream and PortAudio to be released
+// if no new utterance is opened within idleRelease. PortAudio only rescans
+// hardware once every user has terminated it, so holding it forever would
+// hide newly connected devices. The caller must hold mu.
+func (s *PortAudioSink) scheduleIdleLocked() {
+	s.cancelIdleLocked()
+	if !s.initialized {
+		return
+	}
+	gen := s.idleGen
+	s.idleTimer = time.AfterFunc(idleRelease, func() { s.releaseIdle(gen) })
+}
+
+// cancelIdleLocked stops a pending idle release. The caller must hold mu.
+func (s *PortAudioSink) cancelIdleLocked() {
+	s.idleGen++
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+}
+
+func (s *PortAudioSink) releaseIdle(gen int) {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	s.mu.Lock()
+	idle := gen == s.idleGen && !s.running
+	if idle {
+		s.idleTimer = nil
+	}
+	s.mu.Unlock()
+	if !idle {
+		return
+	}
+
+	s.closeStream()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen == s.idleGen && s.initialized {
+		s.initialized = false
+		portaudio.Terminate()
+	}
+}
+
+// start begins playback of the queued audio. The stream is started without
+// holding mu because PortAudio may invoke the callback before returning.
+func (s *PortAudioSink) start() error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	s.mu.Lock()
+	if s.running || s.stopped.Load() || s.stream == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	stream := s.stream
+	s.mu.Unlock()
+
+	if err := stream.Start(); err != nil {
+		return fmt.Errorf("failed to start audio stream: %w", err)
+	}
+
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	return nil
+}
+
+// closeStream closes the current stream. The caller must hold streamMu.
+func (s *PortAudioSink) closeStream() {
+	s.mu.Lock()
+	stream, running := s.stream, s.running
+	s.stream = nil
+	s.ring = nil
+	s.running = false
+	s.mu.Unlock()
+
+	if stream == nil {
+		return
+	}
+	if running {
+		stream.Abort()
+	}
+	stream.Close()
+}
@@ -0,0 +1,52 @@
+package pkg
+
+import "sync/atomic"
+
+// ringBuffer is a fixed-size FIFO of float32 samples. One writer and one
+// reader may use it concurrently without a lock: the writer only advances
+// w and the reader only advances r. Reset must not run concurrently with
+// Read.
+type ringBuffer struct {
+	buf []float32
+	r   atomic.Int64 // total samples read
+	w   atomic.Int64 // total samples written
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]float32, size)}
+}
+
+// Len returns the number of buffered samples
+func (b *ringBuffer) Len() int { return int(b.w.Load() - b.r.Load()) }
+
+// Free returns the number of samples that can be written without overwriting
+func (b *ringBuffer) Free() int { return len(b.buf) - b.Len() }
+
+// Write copies as many samples as fit and returns the count written
+func (b *ringBuffer) Write(p []float32) int {
+	w := b.w.Load()
+	n := min(len(p), len(b.buf)-int(w-b.r.Load()))
+	start := int(w % int64(len(b.buf)))
+	c := copy(b.buf[start:], p[:n])
+	copy(b.buf, p[c:n])
+	// Publish the samples only once they are in place
+	b.w.Store(w + int64(n))
+	return n
+}
+
+// Read moves up to len(p) samples into p and returns the count read
+func (b *ringBuffer) Read(p []float32) int {
+	r := b.r.Load()
+	n := min(len(p), int(b.w.Load()-r))
+	start := int(r % int64(len(b.buf)))
+	c := copy(p[:n], b.buf[start:])
+	copy(p[c:n], b.buf)
+	// Free the space only once the samples have been copied out
+	b.r.Store(r + int64(n))
+	return n
+}
+
+// Reset discards all buffered samples
+func (b *ringBuffer) Reset() {
+	b.r.Store(b.w.Load())
+}
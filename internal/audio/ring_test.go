@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRingBuffer(t *testing.T) {
+	b := newRingBuffer(4)
+
+	if n := b.Write([]float32{1, 2, 3}); n != 3 {
+		t.Fatalf("Expected 3 samples written, got %d", n)
+	}
+	out := make([]float32, 2)
+	if n := b.Read(out); n != 2 || !reflect.DeepEqual(out, []float32{1, 2}) {
+		t.Fatalf("Unexpected read: %d %v", n, out)
+	}
+
+	// This write wraps around the end of the buffer and is cut short
+	if n := b.Write([]float32{4, 5, 6, 7}); n != 3 {
+		t.Fatalf("Expected 3 samples written, got %d", n)
+	}
+	if b.Free() != 0 || b.Len() != 4 {
+		t.Fatalf("Expected full buffer, got len %d free %d", b.Len(), b.Free())
+	}
+
+	out = make([]float32, 8)
+	if n := b.Read(out); n != 4 || !reflect.DeepEqual(out[:n], []float32{3, 4, 5, 6}) {
+		t.Fatalf("Unexpected read: %v", out[:n])
+	}
+
+	b.Write([]float32{9})
+	b.Reset()
+	if b.Len() != 0 || b.Read(out) != 0 {
+		t.Error("Expected empty buffer after Reset")
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (c *closeRecorder) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestPlayStartsBeforeDownloadCompletes(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	first := make([]float32, decodeChunkFrames)
+	var header bytes.Buffer
+	writeWAVHeader(&header, 16000, 1, 0)
+
+	pr, pw := io.Pipe()
+	src := &closeRecorder{Reader: pr, closed: make(chan struct{})}
+	go func() {
+		pw.Write(header.Bytes())
+		pw.Write(floatToPCM16(first))
+	}()
+
+	if err := player.Play(src); err != nil {
+		t.Fatalf("Play failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(sink.Samples()) < len(first) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected audio to reach the sink before the stream ended")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !player.IsPlaying() {
+		t.Error("Expected player to keep playing while the stream is open")
+	}
+
+	pw.Write(floatToPCM16(make([]float32, 100)))
+	pw.Close()
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+
+	if got := len(sink.Samples()); got != len(first)+100 {
+		t.Errorf("Expected %d samples, got %d", len(first)+100, got)
+	}
+	if want := time.Duration(len(first)+100) * time.Second / 16000; player.Position() != want {
+		t.Errorf("Expected position %v, got %v", want, player.Position())
+	}
+	select {
+	case <-src.closed:
+	default:
+		t.Error("Expected the source to be closed after decoding")
+	}
+}
+
+type openCounter struct {
+	*MemorySink
+	opens int
+}
+
+func (s *openCounter) Open(sampleRate float64, channels int) error {
+	s.opens++
+	return s.MemorySink.Open(sampleRate, channels)
+}
+
+func TestEnqueuePlaysBackToBack(t *testing.T) {
+	sink := &openCounter{MemorySink: NewMemorySink()}
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+	player.SetGap(50 * time.Millisecond)
+
+	wav := func(frames int) []byte {
+		var buf bytes.Buffer
+		writeWAVHeader(&buf, 16000, 1, uint32(frames*2))
+		buf.Write(floatToPCM16(make([]float32, frames)))
+		return buf.Bytes()
+	}
+
+	// Keep the first utterance open so the others are queued behind it
+	pr, pw := io.Pipe()
+	written := make(chan struct{})
+	go func() {
+		pw.Write(wav(1600))
+		close(written)
+	}()
+	if err := player.Enqueue(pr); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	second := &closeRecorder{Reader: bytes.NewReader(wav(1600)), closed: make(chan struct{})}
+	if err := player.Enqueue(second); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	player.SetGap(0)
+	if err := player.Enqueue(bytes.NewReader(wav(400))); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	<-written
+	pw.Close()
+
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+	if player.IsPlaying() {
+		t.Error("Expected playback to finish once the queue drained")
+	}
+
+	// The gap captured with the second utterance separates it from the first
+	if got, want := len(sink.Samples()), 1600+800+1600+400; got != want {
+		t.Errorf("Expected %d frames, got %d", want, got)
+	}
+	if sink.opens != 1 {
+		t.Errorf("Expected utterances in the same format to share the open sink, opened %d times", sink.opens)
+	}
+	if want := 400 * time.Second / 16000; player.Position() != want {
+		t.Errorf("Expected position %v, got %v", want, player.Position())
+	}
+	select {
+	case <-second.closed:
+	default:
+		t.Error("Expected the queued source to be closed after decoding")
+	}
+}
+
+func TestStopClearsQueue(t *testing.T) {
+	player := NewAudioPlayerWithSink(NewMemorySink())
+	defer player.Close()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		var header bytes.Buffer
+		writeWAVHeader(&header, 16000, 1, 0)
+		pw.Write(header.Bytes())
+	}()
+	if err := player.Enqueue(pr); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	var header bytes.Buffer
+	writeWAVHeader(&header, 16000, 1, 0)
+	queued := &closeRecorder{Reader: bytes.NewReader(header.Bytes()), closed: make(chan struct{})}
+	if err := player.Enqueue(queued); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	if err := player.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	select {
+	case <-queued.closed:
+	default:
+		t.Error("Expected Stop to discard and close queued audio")
+	}
+	if player.IsPlaying() {
+		t.Error("Expected player to be stopped")
+	}
+}
+
+func TestCallbackReadsWithoutLock(t *testing.T) {
+	sink := NewPortAudioSink()
+	ring := newRingBuffer(64)
+
+	// Hold mu as a writer or control call would; the callback must not need it
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	const total = 10000
+	go func() {
+		buf := make([]float32, 50)
+		for i := 0; i < total; {
+			n := min(len(buf), total-i)
+			for j := range buf[:n] {
+				buf[j] = float32(i + j)
+			}
+			if w := ring.Write(buf[:n]); w > 0 {
+				i += w
+			} else {
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	out := make([]float32, 16)
+	deadline := time.Now().Add(5 * time.Second)
+	for got := 0; got < total; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Callback stalled after %d samples", got)
+		}
+		sink.callback(ring, 1, out)
+		n := int(sink.FramesPlayed()) - got
+		for j, v := range out[:n] {
+			if v != float32(got+j) {
+				t.Fatalf("Sample %d is %v, want %d", got+j, v, got+j)
+			}
+		}
+		for _, v := range out[n:] {
+			if v != 0 {
+				t.Fatalf("Expected silence after the buffered audio, got %v", out)
+			}
+		}
+		got += n
+		if n == 0 {
+			runtime.Gosched()
+		}
+	}
+
+	// A paused sink plays silence and keeps the queued audio
+	ring.Write([]float32{0.5, 0.5})
+	sink.paused.Store(true)
+	sink.callback(ring, 1, out)
+	if out[0] != 0 || ring.Len() != 2 {
+		t.Errorf("Expected silence and the audio kept while paused, got %v", out[:2])
+	}
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSinkStopped is returned by Write when the sink was stopped mid-write
+var ErrSinkStopped = errors.New("audio sink stopped")
+
+// AudioSink receives interleaved float32 PCM frames for output.
+// Open is called before the first Write of every utterance and may be
+// called again with a different format.
+type AudioSink interface {
+	Open(sampleRate float64, channels int) error
+	Write(samples []float32) error
+	Pause() error
+	Resume() error
+	Stop() error  // Discards buffered audio and unblocks pending writes
+	Drain() error // Blocks until all written audio has been output
+	Close() error
+}
+
+// NullSink discards all audio. It is useful on headless servers.
+type NullSink struct{}
+
+// NewNullSink creates a sink that discards audio
+func NewNullSink() *NullSink {
+	return &NullSink{}
+}
+
+func (s *NullSink) Open(sampleRate float64, channels int) error { return nil }
+func (s *NullSink) Write(samples []float32) error               { return nil }
+func (s *NullSink) Pause() error                                { return nil }
+func (s *NullSink) Resume() error                               { return nil }
+func (s *NullSink) Stop() error                                 { return nil }
+func (s *NullSink) Drain() error                                { return nil }
+func (s *NullSink) Close() error                                { return nil }
+
+// MemorySink collects all written audio in memory
+type MemorySink struct {
+	mu         sync.Mutex
+	samples    []float32
+	sampleRate float64
+	channels   int
+}
+
+// NewMemorySink creates a sink that records audio in memory
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+func (s *MemorySink) Open(sampleRate float64, channels int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampleRate = sampleRate
+	s.channels = channels
+	return nil
+}
+
+func (s *MemorySink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+func (s *MemorySink) Pause() error  { return nil }
+func (s *MemorySink) Resume() error { return nil }
+func (s *MemorySink) Stop() error   { return nil }
+func (s *MemorySink) Drain() error  { return nil }
+func (s *MemorySink) Close() error  { return nil }
+
+// Samples returns a copy of the recorded interleaved samples
+func (s *MemorySink) Samples() []float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]float32(nil), s.samples...)
+}
+
+// SampleRate returns the sample rate of the most recent utterance
+func (s *MemorySink) SampleRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sampleRate
+}
+
+// Channels returns the channel count of the most recent utterance
+func (s *MemorySink) Channels() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.channels
+}
+
+// Reset discards recorded audio
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = nil
+}
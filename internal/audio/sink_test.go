@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlayerWithMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	samples := make([]float32, 22050)
+	for i := range samples {
+		samples[i] = float32(i%100) / 100
+	}
+
+	if err := player.play(samples, 22050, 1); err != nil {
+		t.Fatalf("play failed: %v", err)
+	}
+	if err := player.WaitForCompletion(); err != nil {
+		t.Fatalf("WaitForCompletion failed: %v", err)
+	}
+
+	if got := sink.Samples(); len(got) != len(samples) {
+		t.Errorf("Expected %d samples in sink, got %d", len(samples), len(got))
+	}
+	if sink.SampleRate() != 22050 || sink.Channels() != 1 {
+		t.Errorf("Unexpected sink format: %.0f Hz, %d channels", sink.SampleRate(), sink.Channels())
+	}
+	if player.IsPlaying() {
+		t.Error("Expected playback to be finished")
+	}
+}
+
+func TestPlayerSetSink(t *testing.T) {
+	player := NewAudioPlayerWithSink(NewNullSink())
+	defer player.Close()
+
+	sink := NewMemorySink()
+	if err := player.SetSink(sink); err != nil {
+		t.Fatalf("SetSink failed: %v", err)
+	}
+	if player.Sink() != sink {
+		t.Error("Expected Sink to return the new sink")
+	}
+
+	if err := player.play([]float32{0.1, 0.2, 0.3}, 16000, 1); err != nil {
+		t.Fatalf("play failed: %v", err)
+	}
+	player.WaitForCompletion()
+	if len(sink.Samples()) != 3 {
+		t.Errorf("Expected audio to reach the new sink, got %d samples", len(sink.Samples()))
+	}
+}
+
+func TestWAVFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	sink := NewWAVFileSink(path)
+
+	if err := sink.Open(16000, 2); err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := sink.Write([]float32{0, 0.5, -0.5, 1}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := sink.Open(22050, 1); err == nil {
+		t.Error("Expected error when changing format of an open WAV sink")
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read WAV file: %v", err)
+	}
+	if len(data) != 44+8 {
+		t.Fatalf("Expected 52 bytes, got %d", len(data))
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		t.Error("Missing RIFF/WAVE header")
+	}
+	if size := binary.LittleEndian.Uint32(data[40:]); size != 8 {
+		t.Errorf("Expected data size 8, got %d", size)
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:]); rate != 16000 {
+		t.Errorf("Expected sample rate 16000, got %d", rate)
+	}
+	if got := int16(binary.LittleEndian.Uint16(data[50:])); got != 32767 {
+		t.Errorf("Expected full-scale last sample, got %d", got)
+	}
+}
@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavDecoder streams samples from a RIFF/WAVE file. Streams written to a
+// pipe (such as espeak-ng --stdout) often carry a zero or maximal data size,
+// in which case the data chunk is read until EOF.
+type wavDecoder struct {
+	data          io.Reader
+	format        uint16
+	bitsPerSample int
+	sampleRate    int
+	channels      int
+	raw           []byte
+}
+
+// newWAVDecoder reads the WAV header up to the start of the data chunk
+func newWAVDecoder(r io.Reader) (*wavDecoder, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF/WAVE stream")
+	}
+
+	d := &wavDecoder{}
+	haveFormat := false
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, fmt.Errorf("WAV stream has no data chunk: %w", err)
+		}
+		id := string(chunk[0:4])
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid WAV fmt chunk size %d", size)
+			}
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("failed to read WAV fmt chunk: %w", err)
+			}
+			d.format = binary.LittleEndian.Uint16(body[0:2])
+			d.channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			d.sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			d.bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			if d.format == wavFormatExtensible && size >= 26 {
+				// The sub-format GUID starts with the actual format tag
+				d.format = binary.LittleEndian.Uint16(body[24:26])
+			}
+			if err := wavToFloat(nil, nil, d.format, d.bitsPerSample); err != nil {
+				return nil, err
+			}
+			if d.channels == 0 {
+				return nil, fmt.Errorf("invalid WAV channel count 0")
+			}
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("WAV data chunk before fmt chunk")
+			}
+			d.data = r
+			if size != 0 && size != math.MaxUint32 {
+				d.data = io.LimitReader(r, int64(size))
+			}
+			return d, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return nil, fmt.Errorf("failed to skip WAV %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func (d *wavDecoder) SampleRate() float64 { return float64(d.sampleRate) }
+func (d *wavDecoder) Channels() int       { return d.channels }
+
+func (d *wavDecoder) Read(dst []float32) (int, error) {
+	if d.data == nil {
+		return 0, io.EOF
+	}
+	bytesPerSample := d.bitsPerSample / 8
+	if cap(d.raw) < len(dst)*bytesPerSample {
+		d.raw = make([]byte, len(dst)*bytesPerSample)
+	}
+	raw := d.raw[:len(dst)*bytesPerSample]
+
+	n, err := io.ReadFull(d.data, raw)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		// A truncated stream ends at the last whole frame
+		d.data = nil
+		err = nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read WAV data: %w", err)
+	}
+	samples := n / bytesPerSample
+	samples -= samples % d.channels
+	if err := wavToFloat(dst[:samples], raw[:samples*bytesPerSample], d.format, d.bitsPerSample); err != nil {
+		return 0, err
+	}
+	if samples == 0 {
+		return 0, io.EOF
+	}
+	return samples, nil
+}
+
+// decodeWAV decodes a complete RIFF/WAVE stream into memory
+func decodeWAV(r io.Reader) ([]float32, float64, int, error) {
+	return decodeAll(newWAVDecoder(r))
+}
+
+// wavToFloat converts raw little-endian WAV sample data into dst, which must
+// hold one float per sample
+func wavToFloat(dst []float32, raw []byte, format uint16, bitsPerSample int) error {
+	switch {
+	case format == wavFormatPCM && bitsPerSample == 8:
+		for i := range dst {
+			dst[i] = (float32(raw[i]) - 128) / 128
+		}
+	case format == wavFormatPCM && bitsPerSample == 16:
+		for i := range dst {
+			dst[i] = float32(int16(binary.LittleEndian.Uint16(raw[i*2:]))) / 32768
+		}
+	case format == wavFormatPCM && bitsPerSample == 24:
+		for i := range dst {
+			b := raw[i*3:]
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			dst[i] = float32(v) / (1 << 23)
+		}
+	case format == wavFormatPCM && bitsPerSample == 32:
+		for i := range dst {
+			dst[i] = float32(int32(binary.LittleEndian.Uint32(raw[i*4:]))) / (1 << 31)
+		}
+	case format == wavFormatFloat && bitsPerSample == 32:
+		for i := range dst {
+			dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+	case format == wavFormatFloat && bitsPerSample == 64:
+		for i := range dst {
+			dst[i] = float32(math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:])))
+		}
+	case format == wavFormatALaw && bitsPerSample == 8:
+		copy(dst, audio.DecodeALaw(raw))
+	case format == wavFormatMuLaw && bitsPerSample == 8:
+		copy(dst, audio.DecodeMuLaw(raw))
+	default:
+		return fmt.Errorf("unsupported WAV encoding: format %d, %d bits", format, bitsPerSample)
+	}
+	return nil
+}
+
+// writeWAVHeader writes a 44-byte RIFF header for 16-bit PCM audio
+func writeWAVHeader(w io.Writer, sampleRate, channels int, dataBytes uint32) error {
+	return audio.WriteWAVHeader(w, sampleRate, channels, audio.PCM16, dataBytes)
+}
+
+// floatToPCM16 converts float32 samples to little-endian 16-bit PCM
+func floatToPCM16(samples []float32) []byte {
+	return audio.EncodePCM16(samples)
+}
+
+// WAVFileSink writes audio to a 16-bit PCM WAV file. The file is created on
+// the first Open and its header is finalized on Close.
+type WAVFileSink struct {
+	mu         sync.Mutex
+	path       string
+	file       *os.File
+	dataBytes  uint32
+	sampleRate int
+	channels   int
+}
+
+// NewWAVFileSink creates a sink that records audio to path
+func NewWAVFileSink(path string) *WAVFileSink {
+	return &WAVFileSink{path: path}
+}
+
+func (s *WAVFileSink) Open(sampleRate float64, channels int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		if int(sampleRate) != s.sampleRate || channels != s.channels {
+			return fmt.Errorf("WAV sink format is %d Hz/%d ch, cannot switch to %.0f Hz/%d ch",
+				s.sampleRate, s.channels, sampleRate, channels)
+		}
+		return nil
+	}
+
+	f, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to create WAV file: %w", err)
+	}
+	if err := writeWAVHeader(f, int(sampleRate), channels, 0); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write WAV header: %w", err)
+	}
+	s.file = f
+	s.sampleRate = int(sampleRate)
+	s.channels = channels
+	s.dataBytes = 0
+	return nil
+}
+
+func (s *WAVFileSink) Write(samples []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("WAV sink is not open")
+	}
+	n, err := s.file.Write(floatToPCM16(samples))
+	s.dataBytes += uint32(n)
+	return err
+}
+
+func (s *WAVFileSink) Pause() error  { return nil }
+func (s *WAVFileSink) Resume() error { return nil }
+func (s *WAVFileSink) Stop() error   { return nil }
+
+// Drain updates the header so the file is valid even before Close
+func (s *WAVFileSink) Drain() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finalizeLocked()
+}
+
+func (s *WAVFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.finalizeLocked()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
+
+func (s *WAVFileSink) finalizeLocked() error {
+	if s.file == nil {
+		return nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writeWAVHeader(s.file, s.sampleRate, s.channels, s.dataBytes); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func makeWAV(t *testing.T, samples []float32, sampleRate, channels int, dataSize uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeWAVHeader(&buf, sampleRate, channels, dataSize); err != nil {
+		t.Fatalf("writeWAVHeader failed: %v", err)
+	}
+	buf.Write(floatToPCM16(samples))
+	return buf.Bytes()
+}
+
+func TestDecodeWAV(t *testing.T) {
+	samples := []float32{0, 0.5, -0.5, 0.25}
+
+	testCases := []struct {
+		name     string
+		dataSize uint32
+	}{
+		{"exact size", uint32(len(samples) * 2)},
+		{"streamed with zero size", 0},
+		{"streamed with max size", math.MaxUint32},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := makeWAV(t, samples, 22050, 2, tc.dataSize)
+			got, rate, channels, err := decodeWAV(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decodeWAV failed: %v", err)
+			}
+			if rate != 22050 || channels != 2 {
+				t.Errorf("Expected 22050 Hz stereo, got %.0f Hz, %d channels", rate, channels)
+			}
+			if len(got) != len(samples) {
+				t.Fatalf("Expected %d samples, got %d", len(samples), len(got))
+			}
+			for i := range samples {
+				if math.Abs(float64(got[i]-samples[i])) > 1e-3 {
+					t.Errorf("Sample %d: expected %f, got %f", i, samples[i], got[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeWAVSkipsUnknownChunks(t *testing.T) {
+	data := makeWAV(t, []float32{0.5}, 16000, 1, 2)
+
+	// Insert a LIST chunk with an odd size (and pad byte) before the data chunk
+	list := []byte("LIST\x03\x00\x00\x00abc\x00")
+	withList := append(append(append([]byte{}, data[:36]...), list...), data[36:]...)
+	binary.LittleEndian.PutUint32(withList[4:], uint32(len(withList)-8))
+
+	got, _, _, err := decodeWAV(bytes.NewReader(withList))
+	if err != nil {
+		t.Fatalf("decodeWAV failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("Expected 1 sample, got %d", len(got))
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header []byte
+		want   audioFormat
+	}{
+		{"WAV", []byte("RIFF\x00\x00\x00\x00WAVE"), formatWAV},
+		{"MP3 with ID3", []byte("ID3\x04\x00"), formatMP3},
+		{"MP3 frame sync", []byte{0xFF, 0xFB, 0x90, 0x64}, formatMP3},
+		{"Ogg", []byte("OggS\x00\x02"), formatOgg},
+		{"FLAC", []byte("fLaC\x00\x00\x00\x22"), formatFLAC},
+		{"unknown", []byte("hello world!"), formatUnknown},
+		{"empty", nil, formatUnknown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detectFormat(tc.header); got != tc.want {
+				t.Errorf("detectFormat() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPlaySniffsWAV(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	data := makeWAV(t, []float32{0.1, 0.2, 0.3, 0.4}, 16000, 1, 8)
+	if err := player.Play(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Play failed: %v", err)
+	}
+	player.WaitForCompletion()
+
+	if sink.SampleRate() != 16000 || len(sink.Samples()) != 4 {
+		t.Errorf("Expected 4 samples at 16000 Hz, got %d at %.0f Hz", len(sink.Samples()), sink.SampleRate())
+	}
+
+	if err := player.Play(bytes.NewReader([]byte("not audio at all"))); err == nil {
+		t.Error("Expected error for unrecognized format")
+	}
+}
+
+func TestPlayPCM(t *testing.T) {
+	sink := NewMemorySink()
+	player := NewAudioPlayerWithSink(sink)
+	defer player.Close()
+
+	if err := player.PlayPCM([]float32{0.1, -0.1}, 24000, 2); err != nil {
+		t.Fatalf("PlayPCM failed: %v", err)
+	}
+	player.WaitForCompletion()
+	if sink.Channels() != 2 || sink.SampleRate() != 24000 {
+		t.Errorf("Unexpected format: %.0f Hz, %d channels", sink.SampleRate(), sink.Channels())
+	}
+
+	if err := player.PlayPCM(nil, 24000, 1); err == nil {
+		t.Error("Expected error for empty PCM")
+	}
+	if err := player.PlayPCM([]float32{0}, 0, 1); err == nil {
+		t.Error("Expected error for invalid sample rate")
+	}
+}
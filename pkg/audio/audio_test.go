@@ -0,0 +1,235 @@
+package audio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func tone(freq, sampleRate float64, frames int) []float32 {
+	out := make([]float32, frames)
+	for i := range out {
+		out[i] = float32(0.5 * math.Sin(2*math.Pi*freq*float64(i)/sampleRate))
+	}
+	return out
+}
+
+func rms(samples []float32) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestResamplePreservesTone(t *testing.T) {
+	for _, rates := range [][2]float64{{22050, 48000}, {44100, 48000}, {48000, 16000}, {24000, 8000}} {
+		from, to := rates[0], rates[1]
+		in := tone(1000, from, int(from))
+		out := Resample(in, 1, from, to)
+
+		if len(out) != int(to) {
+			t.Errorf("%v -> %v: got %d frames, want %d", from, to, len(out), int(to))
+			continue
+		}
+		want := tone(1000, to, len(out))
+		var maxErr float64
+		for i := len(out) / 4; i < len(out)*3/4; i++ {
+			maxErr = math.Max(maxErr, math.Abs(float64(out[i]-want[i])))
+		}
+		if maxErr > 0.002 {
+			t.Errorf("%v -> %v: max error %.4f", from, to, maxErr)
+		}
+	}
+}
+
+func TestResampleRemovesAliases(t *testing.T) {
+	// 6 kHz cannot be represented at 8 kHz and must not fold back to 2 kHz
+	in := tone(6000, 48000, 48000)
+	out := Resample(in, 1, 48000, 8000)
+	if level := rms(out[1000:7000]); level > 0.001 {
+		t.Errorf("aliased tone has RMS %.4f, want near silence", level)
+	}
+}
+
+func TestResamplerChunked(t *testing.T) {
+	in := make([]float32, 2*4410)
+	copy(in, tone(440, 44100, len(in)))
+	whole := Resample(in, 2, 44100, 48000)
+
+	r := NewResampler(2, 44100, 48000)
+	var chunked []float32
+	for start := 0; start < len(in); start += 2 * 333 {
+		chunked = append(chunked, r.Process(in[start:min(start+2*333, len(in))])...)
+	}
+	chunked = append(chunked, r.Flush()...)
+
+	if len(chunked) != len(whole) {
+		t.Fatalf("chunked output has %d samples, want %d", len(chunked), len(whole))
+	}
+	for i := range whole {
+		if math.Abs(float64(chunked[i]-whole[i])) > 1e-6 {
+			t.Fatalf("sample %d: chunked %v, whole %v", i, chunked[i], whole[i])
+		}
+	}
+}
+
+func TestEncodingsRoundTrip(t *testing.T) {
+	in := tone(440, 8000, 800)
+	for _, tc := range []struct {
+		enc Encoding
+		tol float64
+	}{
+		{PCM16, 1.0 / 32768},
+		{Float32, 0},
+		{MuLaw, 0.02},
+		{ALaw, 0.02},
+	} {
+		data, err := Encode(in, tc.enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != len(in)*tc.enc.BytesPerSample() {
+			t.Errorf("%v: got %d bytes, want %d", tc.enc, len(data), len(in)*tc.enc.BytesPerSample())
+		}
+		out, err := Decode(data, tc.enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range in {
+			if math.Abs(float64(out[i]-in[i])) > tc.tol {
+				t.Fatalf("%v: sample %d decoded to %v, want %v", tc.enc, i, out[i], in[i])
+			}
+		}
+	}
+}
+
+func TestG711ReferenceValues(t *testing.T) {
+	// Silence and full scale from the G.711 tables
+	for _, tc := range []struct {
+		pcm  int16
+		mu   byte
+		alaw byte
+	}{
+		{0, 0xFF, 0xD5},
+		{-1, 0x7F, 0x55},
+		{32767, 0x80, 0xAA},
+		{-32768, 0x00, 0x2A},
+	} {
+		if got := linearToMuLaw(tc.pcm); got != tc.mu {
+			t.Errorf("mu-law(%d) = %#x, want %#x", tc.pcm, got, tc.mu)
+		}
+		if got := linearToALaw(tc.pcm); got != tc.alaw {
+			t.Errorf("A-law(%d) = %#x, want %#x", tc.pcm, got, tc.alaw)
+		}
+	}
+	if got := Float32ToInt16([]float32{2, -2, 0.5}); got[0] != 32767 || got[1] != -32767 || got[2] != 16384 {
+		t.Errorf("Float32ToInt16 = %v", got)
+	}
+}
+
+func TestTrimSilence(t *testing.T) {
+	const rate = 16000
+	// 0.5 s silence, 1 s tone, 0.25 s silence
+	samples := append(make([]float32, rate/2), tone(440, rate, rate)...)
+	samples = append(samples, make([]float32, rate/4)...)
+
+	opts := TrimOptions{Threshold: -40, Window: 10 * time.Millisecond, Padding: 20 * time.Millisecond}
+	start, end := DetectSilence(samples, 1, rate, opts)
+	if want := rate/2 - 320; start != want {
+		t.Errorf("start = %d, want %d", start, want)
+	}
+	if want := rate/2 + rate + 320; end != want {
+		t.Errorf("end = %d, want %d", end, want)
+	}
+
+	// Streaming in odd-sized chunks gives the same result
+	trimmer := NewSilenceTrimmer(1, rate, opts)
+	var streamed []float32
+	for i := 0; i < len(samples); i += 777 {
+		streamed = append(streamed, trimmer.Process(samples[i:min(i+777, len(samples))])...)
+	}
+	streamed = append(streamed, trimmer.Flush()...)
+	if len(streamed) != end-start {
+		t.Errorf("streamed %d frames, want %d", len(streamed), end-start)
+	}
+
+	if got := TrimSilence(make([]float32, rate), 1, rate, opts); len(got) != 0 {
+		t.Errorf("silence trimmed to %d frames, want 0", len(got))
+	}
+}
+
+func TestSilenceIsExact(t *testing.T) {
+	if got := len(Silence(250*time.Millisecond, 22050, 2)); got != 2*5513 {
+		t.Errorf("got %d samples, want %d", got, 2*5513)
+	}
+	if got := Frames(time.Second/3, 48000); got != 16000 {
+		t.Errorf("got %d frames, want 16000", got)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	a := tone(440, 16000, 16000)              // 1 s mono at 16 kHz
+	b := Remix(tone(440, 48000, 24000), 1, 2) // 0.5 s stereo at 48 kHz
+	c := tone(440, 24000, 12000)              // 0.5 s mono at 24 kHz
+
+	result, err := Concat([]Clip{
+		{Label: "a", Samples: a, SampleRate: 16000, Channels: 1},
+		{Label: "b", Samples: b, SampleRate: 48000, Channels: 2},
+		{Label: "c", Samples: c, SampleRate: 24000, Channels: 1, Gap: 250 * time.Millisecond},
+	}, ConcatOptions{Crossfade: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SampleRate != 48000 || result.Channels != 2 {
+		t.Fatalf("format = %v Hz, %d channels, want 48000 Hz, 2 channels", result.SampleRate, result.Channels)
+	}
+
+	// b overlaps a by the crossfade; c follows b after the gap
+	want := []Segment{
+		{Label: "a", StartFrame: 0, EndFrame: 48000, Start: 0, End: time.Second},
+		{Label: "b", StartFrame: 47520, EndFrame: 71520, Start: 990 * time.Millisecond, End: 1490 * time.Millisecond},
+		{Label: "c", StartFrame: 83520, EndFrame: 107520, Start: 1740 * time.Millisecond, End: 2240 * time.Millisecond},
+	}
+	for i, seg := range result.Segments {
+		if seg != want[i] {
+			t.Errorf("segment %d = %+v, want %+v", i, seg, want[i])
+		}
+	}
+	if got := len(result.Samples) / 2; got != 107520 {
+		t.Errorf("got %d frames, want 107520", got)
+	}
+	if got := result.Duration(); got != 2240*time.Millisecond {
+		t.Errorf("duration = %v, want 2.24s", got)
+	}
+	if level := rms(result.Samples[2*71520+2000 : 2*83520-2000]); level != 0 {
+		t.Errorf("gap has RMS %.4f, want silence", level)
+	}
+}
+
+func TestCrossfadeKeepsPower(t *testing.T) {
+	// Uncorrelated noise keeps a constant level through an equal-power fade
+	noise := func(seed uint32) []float32 {
+		out := make([]float32, 8000)
+		for i := range out {
+			seed = seed*1664525 + 1013904223
+			out[i] = float32(seed>>8)/float32(1<<24) - 0.5
+		}
+		return out
+	}
+	result, err := Concat([]Clip{
+		{Samples: noise(1), SampleRate: 8000, Channels: 1},
+		{Samples: noise(2), SampleRate: 8000, Channels: 1},
+	}, ConcatOptions{Crossfade: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, during := rms(result.Samples[:3000]), rms(result.Samples[4100:7900])
+	if math.Abs(during/before-1) > 0.1 {
+		t.Errorf("RMS %.3f during the crossfade, %.3f before", during, before)
+	}
+
+	if _, err := Concat(nil, ConcatOptions{}); err == nil {
+		t.Error("expected an error for no clips")
+	}
+}
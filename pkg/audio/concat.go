@@ -0,0 +1,140 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Clip is one piece of audio to join with Concat
+type Clip struct {
+	Label      string    // Copied to the clip's segment in the result
+	Samples    []float32 // Interleaved samples
+	SampleRate float64
+	Channels   int
+
+	// Gap is silence inserted before the clip. A clip with a gap is not
+	// crossfaded with the one before it.
+	Gap time.Duration
+}
+
+// ConcatOptions control how clips are joined
+type ConcatOptions struct {
+	SampleRate float64       // Output rate; zero uses the highest clip rate
+	Channels   int           // Output channels; zero uses the most clip channels
+	Crossfade  time.Duration // Equal-power overlap between adjacent clips without a gap
+}
+
+// Segment locates one clip in an AudioResult. A crossfaded segment starts
+// where its fade-in begins, so it overlaps the end of the one before it.
+type Segment struct {
+	Label      string
+	StartFrame int // First frame of the clip
+	EndFrame   int // Frame after the clip's last
+	Start      time.Duration
+	End        time.Duration
+}
+
+// AudioResult is audio joined from several clips, with the position of each
+type AudioResult struct {
+	Samples    []float32 // Interleaved samples
+	SampleRate float64
+	Channels   int
+	Segments   []Segment // One per clip, in order
+}
+
+// Duration returns the length of the audio
+func (r *AudioResult) Duration() time.Duration {
+	return frameTime(len(r.Samples)/r.Channels, r.SampleRate)
+}
+
+// WriteWAV writes the audio to w as a WAV file in the given encoding
+func (r *AudioResult) WriteWAV(w io.Writer, enc Encoding) error {
+	return WriteWAV(w, r.Samples, int(math.Round(r.SampleRate)), r.Channels, enc)
+}
+
+// Concat joins clips into one stream, converting each to a common sample
+// rate and channel count. Adjacent clips are separated by their gap or, if
+// they have none, overlapped by the crossfade. The crossfade is shortened
+// to half the length of the shorter clip.
+func Concat(clips []Clip, opts ConcatOptions) (*AudioResult, error) {
+	if len(clips) == 0 {
+		return nil, errors.New("no clips to concatenate")
+	}
+
+	rate, channels := opts.SampleRate, opts.Channels
+	for i, clip := range clips {
+		if clip.SampleRate <= 0 || clip.Channels <= 0 {
+			return nil, fmt.Errorf("clip %d: invalid format %v Hz, %d channels", i, clip.SampleRate, clip.Channels)
+		}
+		if len(clip.Samples)%clip.Channels != 0 {
+			return nil, fmt.Errorf("clip %d: %d samples is not a whole number of %d-channel frames", i, len(clip.Samples), clip.Channels)
+		}
+		if clip.Gap < 0 {
+			return nil, fmt.Errorf("clip %d: negative gap %v", i, clip.Gap)
+		}
+		if opts.SampleRate <= 0 {
+			rate = math.Max(rate, clip.SampleRate)
+		}
+		if opts.Channels <= 0 {
+			channels = max(channels, clip.Channels)
+		}
+	}
+
+	result := &AudioResult{SampleRate: rate, Channels: channels}
+	fade := Frames(opts.Crossfade, rate)
+	prevFrames := 0
+
+	for i, clip := range clips {
+		samples := Resample(clip.Samples, clip.Channels, clip.SampleRate, rate)
+		samples = Remix(samples, clip.Channels, channels)
+		frames := len(samples) / channels
+		total := len(result.Samples) / channels
+
+		start := total
+		switch {
+		case clip.Gap > 0:
+			result.Samples = append(result.Samples, Silence(clip.Gap, rate, channels)...)
+			result.Samples = append(result.Samples, samples...)
+			start += Frames(clip.Gap, rate)
+		case i > 0 && fade > 0:
+			overlap := min(fade, frames/2, prevFrames/2)
+			start -= overlap
+			crossfade(result.Samples[start*channels:], samples[:overlap*channels], channels)
+			result.Samples = append(result.Samples, samples[overlap*channels:]...)
+		default:
+			result.Samples = append(result.Samples, samples...)
+		}
+
+		result.Segments = append(result.Segments, Segment{
+			Label:      clip.Label,
+			StartFrame: start,
+			EndFrame:   start + frames,
+			Start:      frameTime(start, rate),
+			End:        frameTime(start+frames, rate),
+		})
+		prevFrames = frames
+	}
+	return result, nil
+}
+
+// crossfade mixes in into the start of dst with equal-power gains, fading
+// dst out as in fades in
+func crossfade(dst, in []float32, channels int) {
+	frames := len(in) / channels
+	for f := 0; f < frames; f++ {
+		t := (float64(f) + 0.5) / float64(frames) * math.Pi / 2
+		out, gain := float32(math.Cos(t)), float32(math.Sin(t))
+		for ch := 0; ch < channels; ch++ {
+			i := f*channels + ch
+			dst[i] = dst[i]*out + in[i]*gain
+		}
+	}
+}
+
+// frameTime returns the time at which a frame starts
+func frameTime(frame int, sampleRate float64) time.Duration {
+	return time.Duration(math.Round(float64(frame) / sampleRate * float64(time.Second)))
+}
@@ -0,0 +1,244 @@
+// Package audio provides sample-rate conversion and sample-format conversion
+// for interleaved PCM audio. Samples are float32 in the range [-1, 1]
+// throughout; the encodings convert to and from the byte layouts used by
+// WAV files, telephony and provider APIs.
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encoding is a sample format for PCM audio data
+type Encoding int
+
+const (
+	PCM16   Encoding = iota // Signed 16-bit little-endian
+	Float32                 // IEEE 754 32-bit little-endian
+	MuLaw                   // 8-bit G.711 mu-law
+	ALaw                    // 8-bit G.711 A-law
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case PCM16:
+		return "pcm16"
+	case Float32:
+		return "float32"
+	case MuLaw:
+		return "mulaw"
+	case ALaw:
+		return "alaw"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// BytesPerSample returns the size of one encoded sample
+func (e Encoding) BytesPerSample() int {
+	switch e {
+	case PCM16:
+		return 2
+	case Float32:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// Encode converts float32 samples to the given encoding
+func Encode(samples []float32, enc Encoding) ([]byte, error) {
+	switch enc {
+	case PCM16:
+		return EncodePCM16(samples), nil
+	case Float32:
+		return EncodeFloat32(samples), nil
+	case MuLaw:
+		return EncodeMuLaw(samples), nil
+	case ALaw:
+		return EncodeALaw(samples), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %v", enc)
+}
+
+// Decode converts data in the given encoding to float32 samples
+func Decode(data []byte, enc Encoding) ([]float32, error) {
+	switch enc {
+	case PCM16:
+		return DecodePCM16(data), nil
+	case Float32:
+		return DecodeFloat32(data), nil
+	case MuLaw:
+		return DecodeMuLaw(data), nil
+	case ALaw:
+		return DecodeALaw(data), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %v", enc)
+}
+
+// Float32ToInt16 converts samples to 16-bit integers, clipping at full scale
+func Float32ToInt16(samples []float32) []int16 {
+	out := make([]int16, len(samples))
+	for i, s := range samples {
+		out[i] = toInt16(s)
+	}
+	return out
+}
+
+// Int16ToFloat32 converts 16-bit integer samples to float32
+func Int16ToFloat32(samples []int16) []float32 {
+	out := make([]float32, len(samples))
+	for i, s := range samples {
+		out[i] = float32(s) / 32768
+	}
+	return out
+}
+
+func toInt16(s float32) int16 {
+	v := math.Max(-1, math.Min(1, float64(s)))
+	return int16(math.Round(v * 32767))
+}
+
+// EncodePCM16 converts samples to signed 16-bit little-endian PCM
+func EncodePCM16(samples []float32) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(toInt16(s)))
+	}
+	return out
+}
+
+// DecodePCM16 converts signed 16-bit little-endian PCM to samples. A trailing
+// odd byte is ignored.
+func DecodePCM16(data []byte) []float32 {
+	out := make([]float32, len(data)/2)
+	for i := range out {
+		out[i] = float32(int16(binary.LittleEndian.Uint16(data[i*2:]))) / 32768
+	}
+	return out
+}
+
+// EncodeFloat32 converts samples to IEEE 754 little-endian floats
+func EncodeFloat32(samples []float32) []byte {
+	out := make([]byte, len(samples)*4)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(s))
+	}
+	return out
+}
+
+// DecodeFloat32 converts IEEE 754 little-endian floats to samples
+func DecodeFloat32(data []byte) []float32 {
+	out := make([]float32, len(data)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return out
+}
+
+const (
+	muLawBias = 0x84
+	muLawClip = 32635
+)
+
+// EncodeMuLaw converts samples to G.711 mu-law
+func EncodeMuLaw(samples []float32) []byte {
+	out := make([]byte, len(samples))
+	for i, s := range samples {
+		out[i] = linearToMuLaw(toInt16(s))
+	}
+	return out
+}
+
+// DecodeMuLaw converts G.711 mu-law data to samples
+func DecodeMuLaw(data []byte) []float32 {
+	out := make([]float32, len(data))
+	for i, b := range data {
+		out[i] = float32(muLawToLinear(b)) / 32768
+	}
+	return out
+}
+
+func linearToMuLaw(pcm int16) byte {
+	v := int(pcm)
+	sign := 0
+	if v < 0 {
+		v = -v
+		sign = 0x80
+	}
+	v = min(v, muLawClip) + muLawBias
+
+	exponent := 7
+	for mask := 0x4000; v&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (v >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+func muLawToLinear(b byte) int16 {
+	b = ^b
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b & 0x0F)
+	v := ((mantissa << 3) + muLawBias) << exponent
+	v -= muLawBias
+	if b&0x80 != 0 {
+		v = -v
+	}
+	return int16(v)
+}
+
+// EncodeALaw converts samples to G.711 A-law
+func EncodeALaw(samples []float32) []byte {
+	out := make([]byte, len(samples))
+	for i, s := range samples {
+		out[i] = linearToALaw(toInt16(s))
+	}
+	return out
+}
+
+// DecodeALaw converts G.711 A-law data to samples
+func DecodeALaw(data []byte) []float32 {
+	out := make([]float32, len(data))
+	for i, b := range data {
+		out[i] = float32(aLawToLinear(b)) / 32768
+	}
+	return out
+}
+
+func linearToALaw(pcm int16) byte {
+	v := int(pcm) >> 3 // A-law works on 13-bit magnitudes
+	sign := 0x80
+	if v < 0 {
+		v = -v - 1
+		sign = 0
+	}
+	v = min(v, 0xFFF)
+
+	var b int
+	if v < 0x20 {
+		b = v >> 1
+	} else {
+		exponent := 1
+		for t := v >> 5; t > 1; t >>= 1 {
+			exponent++
+		}
+		b = exponent<<4 | (v>>exponent)&0x0F
+	}
+	return byte(b|sign) ^ 0x55
+}
+
+func aLawToLinear(b byte) int16 {
+	b ^= 0x55
+	exponent := int(b>>4) & 0x07
+	mantissa := int(b & 0x0F)
+
+	v := mantissa<<4 + 8
+	if exponent > 0 {
+		v = (v + 0x100) << (exponent - 1)
+	}
+	if b&0x80 == 0 {
+		v = -v
+	}
+	return int16(v)
+}
@@ -0,0 +1,42 @@
+package audio
+
+import "math"
+
+// Remix converts interleaved samples from one channel count to another.
+// Mono is copied to the front left and right channels, 5.1 is folded to
+// stereo with the ITU-R BS.775 coefficients, and any other layout keeps its
+// first channels, averaging everything when mixing down to mono.
+func Remix(samples []float32, from, to int) []float32 {
+	if from == to || from <= 0 || to <= 0 {
+		return samples
+	}
+	frames := len(samples) / from
+	out := make([]float32, frames*to)
+
+	for i := 0; i < frames; i++ {
+		in := samples[i*from : i*from+from]
+		dst := out[i*to : i*to+to]
+
+		switch {
+		case to == 1:
+			var sum float32
+			for _, s := range in {
+				sum += s
+			}
+			dst[0] = sum / float32(from)
+		case from == 1:
+			dst[0] = in[0]
+			dst[1] = in[0]
+		case from == 6 && to == 2:
+			// L R C LFE Ls Rs; the LFE channel is dropped
+			const k = math.Sqrt2 / 2
+			l := in[0] + k*in[2] + k*in[4]
+			r := in[1] + k*in[2] + k*in[5]
+			dst[0] = l / (1 + 2*k)
+			dst[1] = r / (1 + 2*k)
+		default:
+			copy(dst, in)
+		}
+	}
+	return out
+}
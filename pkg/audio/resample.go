@@ -0,0 +1,135 @@
+package audio
+
+import "math"
+
+const (
+	resampleZeroCrossings = 16   // sinc lobes on each side of the kernel
+	resampleTableRes      = 512  // kernel table entries per lobe
+	resampleKaiserBeta    = 8.6  // about 80 dB stop-band attenuation
+	resampleRolloff       = 0.95 // cutoff as a fraction of the lower Nyquist rate
+)
+
+// kernel is a Kaiser-windowed sinc sampled at resampleTableRes points per
+// zero crossing, from the centre outwards
+var kernel = func() []float64 {
+	n := resampleZeroCrossings * resampleTableRes
+	table := make([]float64, n+2)
+	i0Beta := besselI0(resampleKaiserBeta)
+	for i := 0; i <= n; i++ {
+		x := float64(i) / resampleTableRes
+		r := x / resampleZeroCrossings
+		window := besselI0(resampleKaiserBeta*math.Sqrt(1-r*r)) / i0Beta
+		if x == 0 {
+			table[i] = 1
+		} else {
+			table[i] = math.Sin(math.Pi*x) / (math.Pi * x) * window
+		}
+	}
+	return table
+}()
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+	}
+	return sum
+}
+
+// Resampler converts a stream of interleaved samples from one sample rate to
+// another with band-limited (windowed sinc) interpolation. Input can be fed
+// in chunks of any size; call Flush at the end of the stream.
+type Resampler struct {
+	channels int
+	step     float64 // input frames per output frame
+	cutoff   float64 // filter cutoff relative to the input Nyquist rate
+	span     int     // input frames on each side of an output frame
+
+	buf     []float32 // input frames, starting span frames of silence before the stream
+	pos     float64   // position of the next output frame in buf
+	inputs  int       // real input frames received
+	outputs int       // output frames produced
+}
+
+// NewResampler creates a resampler for interleaved audio with the given
+// channel count, converting from one sample rate to another
+func NewResampler(channels int, from, to float64) *Resampler {
+	cutoff := resampleRolloff * math.Min(1, to/from)
+	span := int(math.Ceil(resampleZeroCrossings/cutoff)) + 1
+	return &Resampler{
+		channels: channels,
+		step:     from / to,
+		cutoff:   cutoff,
+		span:     span,
+		buf:      make([]float32, span*channels),
+		pos:      float64(span),
+	}
+}
+
+// Process resamples in and returns the output frames that can be computed
+// so far. The output is delayed by the filter length until Flush.
+func (r *Resampler) Process(in []float32) []float32 {
+	r.buf = append(r.buf, in...)
+	r.inputs += len(in) / r.channels
+	return r.drain(len(r.buf)/r.channels - r.span)
+}
+
+// Flush returns the remaining output. The total output length is the input
+// length scaled by the rate ratio.
+func (r *Resampler) Flush() []float32 {
+	r.buf = append(r.buf, make([]float32, (r.span+1)*r.channels)...)
+	want := int(math.Round(float64(r.inputs) / r.step))
+	out := r.drain(len(r.buf)/r.channels - r.span)
+	if extra := r.outputs - want; extra > 0 {
+		out = out[:len(out)-extra*r.channels]
+		r.outputs = want
+	}
+	return out
+}
+
+// drain computes outputs centred before frame limit of buf, then discards
+// input no longer needed
+func (r *Resampler) drain(limit int) []float32 {
+	var out []float32
+	acc := make([]float64, r.channels)
+	scale := r.cutoff * resampleTableRes
+
+	for r.pos < float64(limit) {
+		centre := int(r.pos)
+		clear(acc)
+		for k := centre - r.span + 1; k <= centre+r.span; k++ {
+			x := math.Abs(r.pos-float64(k)) * scale
+			i := int(x)
+			if i >= len(kernel)-1 {
+				continue
+			}
+			frac := x - float64(i)
+			w := kernel[i] + (kernel[i+1]-kernel[i])*frac
+			for ch := 0; ch < r.channels; ch++ {
+				acc[ch] += w * float64(r.buf[k*r.channels+ch])
+			}
+		}
+		for ch := range acc {
+			out = append(out, float32(acc[ch]*r.cutoff))
+		}
+		r.outputs++
+		r.pos += r.step
+	}
+
+	if drop := int(r.pos) - r.span; drop > 0 {
+		r.buf = append(r.buf[:0], r.buf[drop*r.channels:]...)
+		r.pos -= float64(drop)
+	}
+	return out
+}
+
+// Resample converts interleaved samples from one sample rate to another
+func Resample(samples []float32, channels int, from, to float64) []float32 {
+	if from == to {
+		return samples
+	}
+	r := NewResampler(channels, from, to)
+	return append(r.Process(samples), r.Flush()...)
+}
@@ -0,0 +1,144 @@
+package audio
+
+import (
+	"math"
+	"time"
+)
+
+// TrimOptions control silence detection
+type TrimOptions struct {
+	Threshold float64       // Level in dBFS below which a window counts as silence
+	Window    time.Duration // Length of the windows whose RMS level is measured
+	Padding   time.Duration // Silence kept before the first and after the last sound
+}
+
+// DefaultTrimOptions suit speech from TTS engines, which is silent between
+// clips but may have quiet breaths at the edges
+var DefaultTrimOptions = TrimOptions{
+	Threshold: -50,
+	Window:    10 * time.Millisecond,
+	Padding:   20 * time.Millisecond,
+}
+
+// Frames returns the exact number of frames d lasts at sampleRate
+func Frames(d time.Duration, sampleRate float64) int {
+	return int(math.Round(d.Seconds() * sampleRate))
+}
+
+// Silence returns interleaved silence lasting d, rounded to whole frames
+func Silence(d time.Duration, sampleRate float64, channels int) []float32 {
+	return make([]float32, Frames(d, sampleRate)*channels)
+}
+
+// DetectSilence returns the frame range [start, end) of samples that remains
+// once leading and trailing silence is trimmed. Silent audio yields 0, 0.
+func DetectSilence(samples []float32, channels int, sampleRate float64, opts TrimOptions) (start, end int) {
+	t := NewSilenceTrimmer(channels, sampleRate, opts)
+	t.Process(samples)
+	t.Flush()
+	return t.start, t.end
+}
+
+// TrimSilence returns samples without their leading and trailing silence,
+// keeping the configured padding
+func TrimSilence(samples []float32, channels int, sampleRate float64, opts TrimOptions) []float32 {
+	start, end := DetectSilence(samples, channels, sampleRate, opts)
+	return samples[start*channels : end*channels]
+}
+
+// SilenceTrimmer trims leading and trailing silence from a stream. Leading
+// silence is dropped as it arrives; quiet stretches after the first sound
+// are held back until more sound follows, and dropped at the end of the
+// stream.
+type SilenceTrimmer struct {
+	channels  int
+	window    int // frames per window
+	padding   int // frames of silence kept at each edge
+	threshold float64
+
+	partial []float32 // incomplete window
+	lead    []float32 // the last padding frames of leading silence
+	quiet   []float32 // silence since the last sound
+	started bool
+
+	frames     int // input frames measured so far
+	start, end int // kept range of the input, in frames
+}
+
+// NewSilenceTrimmer creates a trimmer for interleaved audio
+func NewSilenceTrimmer(channels int, sampleRate float64, opts TrimOptions) *SilenceTrimmer {
+	return &SilenceTrimmer{
+		channels:  channels,
+		window:    max(Frames(opts.Window, sampleRate), 1),
+		padding:   Frames(opts.Padding, sampleRate),
+		threshold: math.Pow(10, opts.Threshold/20),
+	}
+}
+
+// Process returns the audio that is known to be kept so far
+func (t *SilenceTrimmer) Process(in []float32) []float32 {
+	t.partial = append(t.partial, in...)
+	size := t.window * t.channels
+
+	var out []float32
+	n := 0
+	for ; n+size <= len(t.partial); n += size {
+		out = t.measure(out, t.partial[n:n+size])
+	}
+	t.partial = append(t.partial[:0], t.partial[n:]...)
+	return out
+}
+
+// Start returns the number of leading input frames trimmed. It is only
+// final once Process has returned audio.
+func (t *SilenceTrimmer) Start() int {
+	return t.start
+}
+
+// Flush returns the remaining audio and the trailing padding
+func (t *SilenceTrimmer) Flush() []float32 {
+	var out []float32
+	if len(t.partial) > 0 {
+		out = t.measure(out, t.partial)
+		t.partial = t.partial[:0]
+	}
+	if !t.started {
+		return out
+	}
+	tail := min(len(t.quiet), t.padding*t.channels)
+	out = append(out, t.quiet[:tail]...)
+	t.end += tail / t.channels
+	t.quiet = t.quiet[:0]
+	return out
+}
+
+// measure classifies one window and appends whatever it releases to out
+func (t *SilenceTrimmer) measure(out, w []float32) []float32 {
+	var sum float64
+	for _, s := range w {
+		sum += float64(s) * float64(s)
+	}
+	loud := math.Sqrt(sum/float64(len(w))) >= t.threshold
+	t.frames += len(w) / t.channels
+
+	switch {
+	case !t.started && !loud:
+		t.lead = append(t.lead, w...)
+		if excess := len(t.lead) - t.padding*t.channels; excess > 0 {
+			t.lead = append(t.lead[:0], t.lead[excess:]...)
+		}
+	case !t.started:
+		t.started = true
+		t.start = t.frames - (len(t.lead)+len(w))/t.channels
+		out = append(append(out, t.lead...), w...)
+		t.lead = nil
+		t.end = t.frames
+	case !loud:
+		t.quiet = append(t.quiet, w...)
+	default:
+		out = append(append(out, t.quiet...), w...)
+		t.quiet = t.quiet[:0]
+		t.end = t.frames
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WAV format tags for each encoding
+var wavFormatTags = map[Encoding]uint16{
+	PCM16:   1,
+	Float32: 3,
+	ALaw:    6,
+	MuLaw:   7,
+}
+
+// WAVHeaderSize returns the size of the header written by WriteWAVHeader
+func WAVHeaderSize(enc Encoding) int {
+	if enc == PCM16 {
+		return 44
+	}
+	// Non-PCM formats carry an extension size and a fact chunk
+	return 58
+}
+
+// WriteWAVHeader writes a RIFF/WAVE header for dataBytes of audio in the
+// given encoding. Streams of unknown length can write a zero size and
+// rewrite the header once the length is known.
+func WriteWAVHeader(w io.Writer, sampleRate, channels int, enc Encoding, dataBytes uint32) error {
+	tag, ok := wavFormatTags[enc]
+	if !ok {
+		return fmt.Errorf("unsupported WAV encoding %v", enc)
+	}
+	bytesPerSample := enc.BytesPerSample()
+	blockAlign := channels * bytesPerSample
+
+	header := make([]byte, 0, WAVHeaderSize(enc))
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(WAVHeaderSize(enc)-8)+dataBytes)
+	header = append(header, "WAVE"...)
+
+	header = append(header, "fmt "...)
+	if enc == PCM16 {
+		header = binary.LittleEndian.AppendUint32(header, 16)
+	} else {
+		header = binary.LittleEndian.AppendUint32(header, 18)
+	}
+	header = binary.LittleEndian.AppendUint16(header, tag)
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bytesPerSample*8))
+
+	if enc != PCM16 {
+		header = binary.LittleEndian.AppendUint16(header, 0) // no extension
+		header = append(header, "fact"...)
+		header = binary.LittleEndian.AppendUint32(header, 4)
+		header = binary.LittleEndian.AppendUint32(header, dataBytes/uint32(blockAlign))
+	}
+
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, dataBytes)
+
+	_, err := w.Write(header)
+	return err
+}
+
+// WriteWAV writes interleaved samples to w as a WAV file in the given encoding
+func WriteWAV(w io.Writer, samples []float32, sampleRate, channels int, enc Encoding) error {
+	data, err := Encode(samples, enc)
+	if err != nil {
+		return err
+	}
+	if err := WriteWAVHeader(w, sampleRate, channels, enc, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,210 @@
+package tts
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	playback "github.com/willwade/go-tts-wrapper/internal/audio"
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+// AudioPlayer decodes synthesized audio and plays it through an AudioSink
+type AudioPlayer = playback.AudioPlayer
+
+// AudioSink receives decoded PCM audio from an AudioPlayer
+type AudioSink = playback.AudioSink
+
+// MemorySink is an AudioSink that records audio in memory
+type MemorySink = playback.MemorySink
+
+// AudioEffects are the speed, pitch and gain applied by an AudioPlayer
+type AudioEffects = playback.Effects
+
+// LoudnessNormalization configures EBU R128 loudness normalization
+type LoudnessNormalization = playback.Normalization
+
+// Gaps are the silences inserted between pieces of speech. They are written
+// as exact frame counts at the output sample rate.
+type Gaps struct {
+	Sentence  time.Duration // Between sentences of streamed or chunked text
+	Paragraph time.Duration // Between paragraphs of streamed or chunked text
+	Utterance time.Duration // Before speech queued behind speech still playing
+}
+
+// Gaps returns the configured silences between pieces of speech
+func (b *BaseProvider) Gaps() Gaps {
+	return b.audioConfig.Gaps
+}
+
+// AudioSinkSetter is implemented by providers whose audio output can be redirected
+type AudioSinkSetter interface {
+	SetAudioSink(sink AudioSink) error
+}
+
+// PrepareAudio applies per-utterance output settings, such as stereo
+// panning, loudness normalization, sample rate, silence trimming and gaps,
+// and the output device, to player before audio is played
+func (b *BaseProvider) PrepareAudio(player *AudioPlayer) error {
+	player.SetPan(b.audioConfig.Pan)
+	player.SetNormalization(b.audioConfig.Normalization)
+	player.SetOutputSampleRate(b.audioConfig.SampleRate)
+	player.SetGap(b.audioConfig.Gaps.Utterance)
+	if b.audioConfig.TrimSilence {
+		player.SetTrimSilence(&audio.DefaultTrimOptions)
+	} else {
+		player.SetTrimSilence(nil)
+	}
+	if b.audioConfig.DeviceID != "" {
+		return player.SetOutputDevice(b.audioConfig.DeviceID)
+	}
+	return nil
+}
+
+// PlayerProvider is implemented by providers that expose their audio player,
+// for example to change the playback speed of an utterance while it plays
+type PlayerProvider interface {
+	Player() *AudioPlayer
+}
+
+// PreparePlayback prepares player to play the provider's audio. It applies
+// PrepareAudio; providers that emulate prosody override it to apply
+// EmulateProsody as well, so helpers such as SpeakFromStream that play on
+// the provider's player get the same output as Speak.
+func (b *BaseProvider) PreparePlayback(player *AudioPlayer) error {
+	return b.PrepareAudio(player)
+}
+
+// EmulateProsody applies the configured rate, pitch and volume to player as
+// playback-time effects. Providers whose services ignore these settings call
+// it after PrepareAudio, so changing them does not require re-synthesis.
+func (b *BaseProvider) EmulateProsody(player *AudioPlayer) {
+	player.SetEffects(AudioEffects{
+		Speed: b.audioConfig.Rate,
+		Pitch: b.audioConfig.Pitch,
+		Gain:  b.audioConfig.Volume,
+	})
+}
+
+// WriteAudioFile writes synthesized audio from r to filename. If loudness
+// normalization or an output sample rate is configured and filename ends in
+// .wav, the audio is decoded, processed and written as WAV. Otherwise it is
+// copied unchanged, so MP3 and other encoded files keep their format.
+func (b *BaseProvider) WriteAudioFile(filename string, r io.Reader) error {
+	opts := b.exportOptions()
+	convert := (opts.Normalization.Enabled || opts.SampleRate > 0) &&
+		strings.EqualFold(filepath.Ext(filename), ".wav")
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	if convert {
+		err = playback.ExportWAV(f, r, opts)
+	} else {
+		_, err = io.Copy(f, r)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// WritePCMFile writes interleaved float32 samples to filename as WAV,
+// applying the configured loudness normalization and output sample rate
+func (b *BaseProvider) WritePCMFile(filename string, samples []float32, sampleRate, channels int) error {
+	opts := b.exportOptions()
+	if opts.Normalization.Enabled {
+		samples = append([]float32(nil), samples...)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	err = playback.ExportPCM(f, samples, float64(sampleRate), channels, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (b *BaseProvider) exportOptions() playback.ExportOptions {
+	return playback.ExportOptions{
+		SampleRate:    b.audioConfig.SampleRate,
+		Normalization: b.audioConfig.Normalization,
+	}
+}
+
+// MeasureLoudness returns the integrated loudness in LUFS and the true peak
+// in dBTP of interleaved float32 samples
+func MeasureLoudness(samples []float32, sampleRate float64, channels int) (lufs, truePeak float64) {
+	return playback.MeasureLoudness(samples, sampleRate, channels)
+}
+
+// NewAudioPlayer creates a player for the default audio device.
+// The device is only opened when audio is first played.
+func NewAudioPlayer() (*AudioPlayer, error) {
+	return playback.NewAudioPlayer()
+}
+
+// NewAudioPlayerWithSink creates a player that writes to sink
+func NewAudioPlayerWithSink(sink AudioSink) *AudioPlayer {
+	return playback.NewAudioPlayerWithSink(sink)
+}
+
+// NewPortAudioSink creates a sink for the default PortAudio output device
+func NewPortAudioSink() AudioSink {
+	return playback.NewPortAudioSink()
+}
+
+// NewPortAudioSinkForDevice creates a sink for the output device with the
+// given ID, as listed by ListAudioDevices
+func NewPortAudioSinkForDevice(deviceID string) AudioSink {
+	return playback.NewPortAudioSinkForDevice(deviceID)
+}
+
+// NewNullSink creates a sink that discards all audio
+func NewNullSink() AudioSink {
+	return playback.NewNullSink()
+}
+
+// NewWAVFileSink creates a sink that records audio to a 16-bit WAV file
+func NewWAVFileSink(path string) AudioSink {
+	return playback.NewWAVFileSink(path)
+}
+
+// NewMemorySink creates a sink that records audio in memory
+func NewMemorySink() *MemorySink {
+	return playback.NewMemorySink()
+}
+
+// OpusSupported reports whether Ogg/Opus audio can be played and decoded.
+// Opus needs libopus, so it is only available when built with the opus tag.
+func OpusSupported() bool {
+	return playback.OpusSupported()
+}
+
+// DecodeAudio decodes WAV, MP3, FLAC or Ogg/Opus audio to interleaved float32
+// samples, returning the sample rate and channel count. Ogg/Opus needs the
+// opus build tag; see OpusSupported.
+func DecodeAudio(r io.Reader) ([]float32, float64, int, error) {
+	return playback.Decode(r)
+}
+
+// AudioDuration decodes the audio in r and returns its length
+func AudioDuration(r io.Reader) (time.Duration, error) {
+	samples, rate, channels, err := playback.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(float64(len(samples)/channels) / rate * float64(time.Second)), nil
+}
+
+// ConvertToWAV decodes audio in any supported format and writes it as 16-bit WAV
+func ConvertToWAV(w io.Writer, r io.Reader) error {
+	return playback.ConvertToWAV(w, r)
+}
@@ -0,0 +1,37 @@
+package tts_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func TestWriteAudioFileKeepsEncodedFormats(t *testing.T) {
+	base := tts.NewBaseProvider(tts.TTSConfig{})
+	if err := base.SetProperty("normalize", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Normalization only rewrites WAV files; MP3 is copied unchanged
+	mp3 := []byte("ID3 mock mp3 data")
+	name := filepath.Join(t.TempDir(), "speech.mp3")
+	if err := base.WriteAudioFile(name, bytes.NewReader(mp3)); err != nil {
+		t.Fatalf("WriteAudioFile failed: %v", err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, mp3) {
+		t.Errorf("Expected the MP3 unchanged, got %q", got)
+	}
+
+	err = base.WriteAudioFile(filepath.Join(t.TempDir(), "speech.wav"), strings.NewReader("not audio"))
+	if err == nil {
+		t.Error("Expected an error decoding invalid audio for a WAV file")
+	}
+}
@@ -13,10 +13,16 @@ type TTSConfig struct {
 
 // AudioConfig contains settings for audio output
 type AudioConfig struct {
-	Rate     float64 // Speech rate (1.0 is normal)
-	Pitch    float64 // Voice pitch (1.0 is normal)
-	Volume   float64 // Volume level (1.0 is normal)
-	DeviceID string  // Output device ID
+	Rate       float64 // Speech rate (1.0 is normal)
+	Pitch      float64 // Voice pitch (1.0 is normal)
+	Volume     float64 // Volume level (1.0 is normal)
+	Pan        float64 // Stereo position (-1 left, 0 centre, 1 right)
+	DeviceID   string  // Output device ID
+	SampleRate int     // Output sample rate in Hz (0 keeps the source rate)
+
+	Normalization LoudnessNormalization // Loudness normalization (disabled by default)
+	TrimSilence   bool                  // Trim leading and trailing silence
+	Gaps          Gaps                  // Silence between sentences, paragraphs and utterances
 }
 
 // Voice represents a TTS voice with standardized properties
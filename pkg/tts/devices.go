@@ -2,54 +2,43 @@
 package tts
 
 import (
-	"fmt"
+	"context"
+	"time"
 
-	"github.com/gordonklaus/portaudio"
+	playback "github.com/willwade/go-tts-wrapper/internal/audio"
 )
 
-type AudioDevice struct {
-    ID          string
-    Name        string
-    IsDefault   bool
-    SampleRates []int
-}
+// AudioDevice describes an audio output device
+type AudioDevice = playback.OutputDevice
+
+// AudioDeviceChange reports output devices that appeared or disappeared
+type AudioDeviceChange = playback.DeviceChange
 
+// ListAudioDevices returns the available output devices. The list is
+// cached after the first call; use RefreshAudioDevices to rescan.
 func ListAudioDevices() ([]AudioDevice, error) {
-    err := portaudio.Initialize()
-    if err != nil {
-        return nil, fmt.Errorf("failed to initialize PortAudio: %w", err)
-    }
-    defer portaudio.Terminate()
-
-    devices, err := portaudio.Devices()
-    if err != nil {
-        return nil, fmt.Errorf("failed to get devices: %w", err)
-    }
-
-    audioDevices := make([]AudioDevice, 0, len(devices))
-    for _, dev := range devices {
-        if dev.MaxOutputChannels > 0 {
-            audioDevices = append(audioDevices, AudioDevice{
-                ID:          dev.Name,
-                Name:        dev.Name,
-                IsDefault:   dev.Name == portaudio.DefaultOutputDevice.Name,
-                SampleRates: []int{44100, 48000}, // Common sample rates
-            })
-        }
-    }
-    return audioDevices, nil
+	return playback.OutputDevices()
+}
+
+// RefreshAudioDevices rescans the output devices
+func RefreshAudioDevices() ([]AudioDevice, error) {
+	return playback.RescanOutputDevices()
 }
 
+// WatchAudioDevices polls for output device changes, such as headphones
+// being plugged in, until ctx is cancelled
+func WatchAudioDevices(ctx context.Context, interval time.Duration) <-chan AudioDeviceChange {
+	return playback.WatchOutputDevices(ctx, interval)
+}
+
+// SetDefaultDevice routes all players without a device of their own to the
+// output device with the given ID. An empty ID restores the system default.
 func SetDefaultDevice(deviceID string) error {
-    devices, err := ListAudioDevices()
-    if err != nil {
-        return err
-    }
-
-    for _, dev := range devices {
-        if dev.ID == deviceID {
-            return nil // Device exists
-        }
-    }
-    return fmt.Errorf("device not found: %s", deviceID)
-}
\ No newline at end of file
+	if deviceID != "" {
+		if err := playback.ValidateDevice(deviceID); err != nil {
+			return err
+		}
+	}
+	playback.SetDefaultDevice(deviceID)
+	return nil
+}
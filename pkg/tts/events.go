@@ -0,0 +1,148 @@
+package tts
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Events that can be passed to Connect. Timing events receive a Timing;
+// EventStart and EventEnd receive nil.
+const (
+	EventStart    = "onStart"    // Playback of an utterance started
+	EventEnd      = "onEnd"      // Playback of an utterance finished or was stopped
+	EventWord     = "onWord"     // A word started playing
+	EventSentence = "onSentence" // A sentence started playing
+	EventViseme   = "onViseme"   // A mouth shape started playing
+	EventMark     = "onMark"     // An SSML <mark> was reached
+)
+
+// timingEvents maps each timing kind to the event it fires
+var timingEvents = map[TimingKind]string{
+	WordTiming:     EventWord,
+	SentenceTiming: EventSentence,
+	VisemeTiming:   EventViseme,
+	MarkTiming:     EventMark,
+}
+
+// timingPollInterval is how often playback position is checked for timing events
+const timingPollInterval = 10 * time.Millisecond
+
+// Connect registers callback to be called when eventName fires
+func (b *BaseProvider) Connect(eventName string, callback func(interface{})) error {
+	switch eventName {
+	case EventStart, EventEnd, EventWord, EventSentence, EventViseme, EventMark:
+	default:
+		return fmt.Errorf("unknown event: %s", eventName)
+	}
+	if callback == nil {
+		return fmt.Errorf("callback for %s is nil", eventName)
+	}
+
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string][]func(interface{}))
+	}
+	b.handlers[eventName] = append(b.handlers[eventName], callback)
+	return nil
+}
+
+// Listening reports whether any callback is connected to eventName, so
+// providers can skip fetching timing data nobody uses
+func (b *BaseProvider) Listening(eventName string) bool {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+	return len(b.handlers[eventName]) > 0
+}
+
+// Emit calls the callbacks connected to eventName
+func (b *BaseProvider) Emit(eventName string, data interface{}) {
+	b.handlersMu.Lock()
+	callbacks := append([]func(interface{}){}, b.handlers[eventName]...)
+	b.handlersMu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(data)
+	}
+}
+
+// PlayWithEvents plays r on player and fires EventStart, a timing event as
+// playback reaches each of timings, and EventEnd once playback finishes or
+// is stopped. Timings are relative to the start of the audio in r.
+func (b *BaseProvider) PlayWithEvents(player *AudioPlayer, r io.Reader, timings []Timing) error {
+	b.handlersMu.Lock()
+	listening := len(b.handlers) > 0
+	b.handlersMu.Unlock()
+	if !listening {
+		return player.Play(r)
+	}
+
+	timings = append([]Timing(nil), timings...)
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Start < timings[j].Start })
+
+	if err := player.Play(r); err != nil {
+		return err
+	}
+	b.Emit(EventStart, nil)
+
+	done := make(chan struct{})
+	go func() {
+		player.WaitForCompletion()
+		close(done)
+	}()
+	go b.dispatchTimings(player, timings, done)
+	return nil
+}
+
+// dispatchTimings fires timing events as player's position passes them
+// until done is closed
+func (b *BaseProvider) dispatchTimings(player *AudioPlayer, timings []Timing, done <-chan struct{}) {
+	ticker := time.NewTicker(timingPollInterval)
+	defer ticker.Stop()
+
+	next := 0
+	fire := func() {
+		// Timings are in source time, before gaps, trimming and speed changes
+		pos := player.SourcePosition()
+		for ; next < len(timings) && timings[next].Start <= pos; next++ {
+			if event, ok := timingEvents[timings[next].Kind]; ok {
+				b.Emit(event, timings[next])
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			fire()
+		case <-done:
+			// Sinks that are not paced in real time finish before the first tick
+			fire()
+			b.Emit(EventEnd, nil)
+			return
+		}
+	}
+}
+
+// FillTimingEnds sets the end of each word and sentence timing that has
+// none to the start of the next timing of the same kind, or to total for
+// the last one. Providers whose marks only carry a start time use it.
+func FillTimingEnds(timings []Timing, total time.Duration) {
+	last := make(map[TimingKind]int)
+	for i, t := range timings {
+		if t.Kind != WordTiming && t.Kind != SentenceTiming {
+			continue
+		}
+		if prev, ok := last[t.Kind]; ok && timings[prev].End == 0 {
+			timings[prev].End = t.Start
+		}
+		last[t.Kind] = i
+	}
+	for _, i := range last {
+		if timings[i].End == 0 {
+			timings[i].End = max(total, timings[i].Start)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package tts_test
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func TestPlayWithEvents(t *testing.T) {
+	base := tts.NewBaseProvider(tts.TTSConfig{})
+	if err := base.Connect("onSomething", func(interface{}) {}); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+
+	var mu sync.Mutex
+	var got []string
+	ended := make(chan struct{})
+	record := func(name string) func(interface{}) {
+		return func(data interface{}) {
+			mu.Lock()
+			defer mu.Unlock()
+			entry := name
+			if timing, ok := data.(tts.Timing); ok {
+				entry += ":" + timing.Text
+			}
+			got = append(got, entry)
+			if name == tts.EventEnd {
+				close(ended)
+			}
+		}
+	}
+	for _, event := range []string{tts.EventStart, tts.EventEnd, tts.EventWord, tts.EventMark} {
+		if err := base.Connect(event, record(event)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if base.Listening(tts.EventViseme) {
+		t.Error("Listening reports a viseme callback that was never connected")
+	}
+
+	var wav bytes.Buffer
+	if err := audio.WriteWAV(&wav, make([]float32, 8000), 8000, 1, audio.PCM16); err != nil {
+		t.Fatal(err)
+	}
+	timings := []tts.Timing{
+		{Kind: tts.WordTiming, Text: "world", Start: 600 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "hello", Start: 100 * time.Millisecond},
+		{Kind: tts.MarkTiming, Text: "here", Start: 500 * time.Millisecond},
+		{Kind: tts.VisemeTiming, Text: "p", Start: 550 * time.Millisecond},
+	}
+
+	player := tts.NewAudioPlayerWithSink(tts.NewMemorySink())
+	if err := base.PlayWithEvents(player, &wav, timings); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onEnd was not fired")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"onStart", "onWord:hello", "onMark:here", "onWord:world", "onEnd"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestFillTimingEnds(t *testing.T) {
+	timings := []tts.Timing{
+		{Kind: tts.SentenceTiming, Text: "Hi there.", Start: 0},
+		{Kind: tts.WordTiming, Text: "Hi", Start: 0},
+		{Kind: tts.VisemeTiming, Text: "k", Start: 50 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "there", Start: 300 * time.Millisecond},
+	}
+	tts.FillTimingEnds(timings, time.Second)
+
+	ends := []time.Duration{time.Second, 300 * time.Millisecond, 0, time.Second}
+	for i, want := range ends {
+		if timings[i].End != want {
+			t.Errorf("%s ends at %v, want %v", timings[i].Text, timings[i].End, want)
+		}
+	}
+}
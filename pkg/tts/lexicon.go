@@ -0,0 +1,469 @@
+package tts
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const plsNamespace = "http://www.w3.org/2005/01/pronunciation-lexicon"
+
+// LexiconEntry describes how a single word or phrase should be pronounced
+type LexiconEntry struct {
+	Graphemes []string // Written forms the entry applies to
+	Phoneme   string   // Pronunciation in the entry's alphabet
+	Alphabet  string   // Overrides the lexicon alphabet when set
+	Alias     string   // Replacement text, used when no phoneme is given or SSML is unavailable
+}
+
+// Lexicon holds custom pronunciations that are applied before synthesis.
+// Use Add to extend a lexicon once it is in use; changing Entries directly
+// after the first lookup is unsupported, as the compiled matchers are not
+// rebuilt.
+type Lexicon struct {
+	Name          string
+	Language      string // BCP 47 language tag, e.g. "en-US"
+	Alphabet      string // Phonetic alphabet, "ipa" or "x-sampa"
+	CaseSensitive bool
+	Entries       []LexiconEntry
+
+	mu       sync.Mutex // guards matchers, so providers can share a lexicon
+	matchers []lexiconMatcher
+}
+
+// LexiconUploader is implemented by providers that can store a lexicon server-side
+type LexiconUploader interface {
+	UploadLexicon(ctx context.Context, lexicon *Lexicon) error
+}
+
+type lexiconMatcher struct {
+	grapheme string
+	entry    *LexiconEntry
+}
+
+// NewLexicon creates an empty lexicon
+func NewLexicon(name, language, alphabet string) *Lexicon {
+	return &Lexicon{
+		Name:     name,
+		Language: language,
+		Alphabet: alphabet,
+	}
+}
+
+// Add appends an entry for a single grapheme
+func (l *Lexicon) Add(grapheme, phoneme, alias string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Entries = append(l.Entries, LexiconEntry{
+		Graphemes: []string{grapheme},
+		Phoneme:   phoneme,
+		Alias:     alias,
+	})
+	l.matchers = nil
+}
+
+type plsDocument struct {
+	XMLName  xml.Name    `xml:"lexicon"`
+	Version  string      `xml:"version,attr"`
+	Alphabet string      `xml:"alphabet,attr"`
+	Language string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Lexemes  []plsLexeme `xml:"lexeme"`
+}
+
+type plsLexeme struct {
+	Graphemes []string     `xml:"grapheme"`
+	Phonemes  []plsPhoneme `xml:"phoneme,omitempty"`
+	Aliases   []string     `xml:"alias,omitempty"`
+}
+
+type plsPhoneme struct {
+	Alphabet string `xml:"alphabet,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+// LoadPLS reads a W3C Pronunciation Lexicon Specification document
+func LoadPLS(r io.Reader) (*Lexicon, error) {
+	var doc plsDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse PLS lexicon: %w", err)
+	}
+
+	lex := NewLexicon("", doc.Language, doc.Alphabet)
+	for _, lx := range doc.Lexemes {
+		entry := LexiconEntry{}
+		for _, g := range lx.Graphemes {
+			if g = strings.TrimSpace(g); g != "" {
+				entry.Graphemes = append(entry.Graphemes, g)
+			}
+		}
+		if len(entry.Graphemes) == 0 {
+			continue
+		}
+		// PLS allows several pronunciations per lexeme; the first one is preferred
+		if len(lx.Phonemes) > 0 {
+			entry.Phoneme = strings.TrimSpace(lx.Phonemes[0].Value)
+			entry.Alphabet = lx.Phonemes[0].Alphabet
+		}
+		if len(lx.Aliases) > 0 {
+			entry.Alias = strings.TrimSpace(lx.Aliases[0])
+		}
+		if entry.Phoneme == "" && entry.Alias == "" {
+			continue
+		}
+		lex.Entries = append(lex.Entries, entry)
+	}
+	return lex, nil
+}
+
+// LoadLexiconCSV reads a lexicon from CSV rows of grapheme,phoneme[,alias].
+// A leading header row starting with "grapheme" is skipped.
+func LoadLexiconCSV(r io.Reader) (*Lexicon, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV lexicon: %w", err)
+	}
+
+	lex := NewLexicon("", "", "ipa")
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && strings.EqualFold(strings.TrimSpace(rec[0]), "grapheme") {
+			continue
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("lexicon line %d: expected at least 2 fields, got %d", i+1, len(rec))
+		}
+		grapheme := strings.TrimSpace(rec[0])
+		phoneme := strings.TrimSpace(rec[1])
+		alias := ""
+		if len(rec) > 2 {
+			alias = strings.TrimSpace(rec[2])
+		}
+		if grapheme == "" || (phoneme == "" && alias == "") {
+			continue
+		}
+		lex.Add(grapheme, phoneme, alias)
+	}
+	return lex, nil
+}
+
+// LoadLexiconFile loads a PLS (.pls, .xml) or CSV (.csv) lexicon from disk.
+// The lexicon is named after the file.
+func LoadLexiconFile(path string) (*Lexicon, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lex *Lexicon
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pls", ".xml":
+		lex, err = LoadPLS(f)
+	case ".csv":
+		lex, err = LoadLexiconCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported lexicon format: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lex.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return lex, nil
+}
+
+// WritePLS serializes the lexicon as a PLS document
+func (l *Lexicon) WritePLS(w io.Writer) error {
+	alphabet := l.Alphabet
+	if alphabet == "" {
+		alphabet = "ipa"
+	}
+	doc := plsDocument{
+		XMLName:  xml.Name{Space: plsNamespace, Local: "lexicon"},
+		Version:  "1.0",
+		Alphabet: alphabet,
+		Language: l.Language,
+	}
+	for _, e := range l.Entries {
+		lx := plsLexeme{Graphemes: e.Graphemes}
+		if e.Phoneme != "" {
+			lx.Phonemes = []plsPhoneme{{Alphabet: e.Alphabet, Value: e.Phoneme}}
+		}
+		if e.Alias != "" {
+			lx.Aliases = []string{e.Alias}
+		}
+		doc.Lexemes = append(doc.Lexemes, lx)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write PLS lexicon: %w", err)
+	}
+	return enc.Flush()
+}
+
+// ApplyText replaces graphemes with their aliases for providers without SSML.
+// Entries that only carry a phoneme are left untouched.
+func (l *Lexicon) ApplyText(text string) string {
+	return l.replace(text, func(match string, e *LexiconEntry) string {
+		if e.Alias == "" {
+			return match
+		}
+		return e.Alias
+	})
+}
+
+// ApplySSML rewrites the text content of an SSML document, wrapping lexicon
+// matches in <phoneme> or <sub> elements. Text already inside a <phoneme> or
+// <sub> element is left alone.
+func (l *Lexicon) ApplySSML(ssml string) string {
+	var out strings.Builder
+	skipDepth := 0
+
+	for len(ssml) > 0 {
+		if ssml[0] == '<' {
+			end := strings.IndexByte(ssml, '>')
+			if end < 0 {
+				out.WriteString(ssml)
+				break
+			}
+			tag := ssml[:end+1]
+			out.WriteString(tag)
+			ssml = ssml[end+1:]
+
+			switch name, closing, selfClosing := ssmlTagName(tag); {
+			case name != "phoneme" && name != "sub", selfClosing:
+			case closing:
+				if skipDepth > 0 {
+					skipDepth--
+				}
+			default:
+				skipDepth++
+			}
+			continue
+		}
+
+		next := strings.IndexByte(ssml, '<')
+		if next < 0 {
+			next = len(ssml)
+		}
+		segment := ssml[:next]
+		ssml = ssml[next:]
+
+		if skipDepth > 0 {
+			out.WriteString(segment)
+			continue
+		}
+		out.WriteString(l.replaceSSMLText(unescapeXML(segment)))
+	}
+	return out.String()
+}
+
+// TextToSSML converts plain text to an SSML document with lexicon entries applied
+func (l *Lexicon) TextToSSML(text string) string {
+	return "<speak>" + l.SSMLFragment(text) + "</speak>"
+}
+
+// SSMLFragment converts plain text to SSML content with lexicon entries
+// applied, for wrapping in a provider's own speak element
+func (l *Lexicon) SSMLFragment(text string) string {
+	return l.replaceSSMLText(text)
+}
+
+func (l *Lexicon) replaceSSMLText(text string) string {
+	var out strings.Builder
+	last := 0
+	l.each(text, func(start, end int, e *LexiconEntry) {
+		out.WriteString(escapeXML(text[last:start]))
+		match := escapeXML(text[start:end])
+		switch {
+		case e.Phoneme != "":
+			alphabet := e.Alphabet
+			if alphabet == "" {
+				alphabet = l.Alphabet
+			}
+			if alphabet == "" {
+				alphabet = "ipa"
+			}
+			fmt.Fprintf(&out, `<phoneme alphabet="%s" ph="%s">%s</phoneme>`,
+				escapeXML(alphabet), escapeXML(e.Phoneme), match)
+		default:
+			fmt.Fprintf(&out, `<sub alias="%s">%s</sub>`, escapeXML(e.Alias), match)
+		}
+		last = end
+	})
+	out.WriteString(escapeXML(text[last:]))
+	return out.String()
+}
+
+func (l *Lexicon) replace(text string, fn func(match string, e *LexiconEntry) string) string {
+	var out strings.Builder
+	last := 0
+	l.each(text, func(start, end int, e *LexiconEntry) {
+		out.WriteString(text[last:start])
+		out.WriteString(fn(text[start:end], e))
+		last = end
+	})
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// each calls fn for every whole-word lexicon match in text, longest grapheme first
+func (l *Lexicon) each(text string, fn func(start, end int, e *LexiconEntry)) {
+	matchers := l.compile()
+	if len(matchers) == 0 {
+		return
+	}
+
+	prevWord := false
+	for i := 0; i < len(text); {
+		if !prevWord {
+			if m, ok := l.matchAt(text, i, matchers); ok {
+				end := i + len(m.grapheme)
+				fn(i, end, m.entry)
+				r, _ := utf8.DecodeLastRuneInString(text[:end])
+				prevWord = isWordRune(r)
+				i = end
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		prevWord = isWordRune(r)
+		i += size
+	}
+}
+
+func (l *Lexicon) matchAt(text string, i int, matchers []lexiconMatcher) (lexiconMatcher, bool) {
+	rest := text[i:]
+	for _, m := range matchers {
+		if len(rest) < len(m.grapheme) {
+			continue
+		}
+		candidate := rest[:len(m.grapheme)]
+		if l.CaseSensitive {
+			if candidate != m.grapheme {
+				continue
+			}
+		} else if !strings.EqualFold(candidate, m.grapheme) {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(rest[len(m.grapheme):]); next != utf8.RuneError && isWordRune(next) {
+			continue
+		}
+		return m, true
+	}
+	return lexiconMatcher{}, false
+}
+
+func (l *Lexicon) compile() []lexiconMatcher {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.matchers != nil {
+		return l.matchers
+	}
+	matchers := make([]lexiconMatcher, 0, len(l.Entries))
+	for i := range l.Entries {
+		for _, g := range l.Entries[i].Graphemes {
+			if g != "" {
+				matchers = append(matchers, lexiconMatcher{grapheme: g, entry: &l.Entries[i]})
+			}
+		}
+	}
+	sort.SliceStable(matchers, func(a, b int) bool {
+		return len(matchers[a].grapheme) > len(matchers[b].grapheme)
+	})
+	l.matchers = matchers
+	return matchers
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// ssmlTagName returns the local name of an SSML tag and whether it closes or self-closes
+func ssmlTagName(tag string) (name string, closing, selfClosing bool) {
+	inner := strings.TrimSuffix(strings.TrimPrefix(tag, "<"), ">")
+	if strings.HasPrefix(inner, "/") {
+		closing = true
+		inner = inner[1:]
+	}
+	if strings.HasSuffix(inner, "/") {
+		selfClosing = true
+		inner = strings.TrimSuffix(inner, "/")
+	}
+	if fields := strings.Fields(inner); len(fields) > 0 {
+		name = fields[0]
+	}
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name, closing, selfClosing
+}
+
+var (
+	xmlEscaper   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+	xmlUnescaper = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&amp;", "&")
+)
+
+func escapeXML(s string) string {
+	return xmlEscaper.Replace(s)
+}
+
+func unescapeXML(s string) string {
+	return xmlUnescaper.Replace(s)
+}
+
+// SetLexicon sets the pronunciation lexicon applied before synthesis
+func (b *BaseProvider) SetLexicon(lexicon *Lexicon) {
+	b.lexicon = lexicon
+}
+
+// SSMLEnvelope wraps SSML content in the speak element a service accepts
+type SSMLEnvelope func(body string) string
+
+// SetSSMLEnvelope sets how PrepareText wraps lexicon output for services
+// that need more than a bare <speak> element, such as a voice element
+func (b *BaseProvider) SetSSMLEnvelope(envelope SSMLEnvelope) {
+	b.ssmlEnvelope = envelope
+}
+
+// PrepareText applies the lexicon to plain text. SSML-capable providers get
+// an SSML document back so phonemes can be used; isSSML reports whether the
+// returned text needs to be synthesized as SSML. Providers whose engines
+// ignore <phoneme> should pass ssmlCapable=false to get aliases instead.
+func (b *BaseProvider) PrepareText(text string, ssmlCapable bool) (prepared string, isSSML bool) {
+	if b.lexicon == nil || len(b.lexicon.Entries) == 0 {
+		return text, false
+	}
+	if !ssmlCapable {
+		return b.lexicon.ApplyText(text), false
+	}
+	if b.ssmlEnvelope != nil {
+		return b.ssmlEnvelope(b.lexicon.SSMLFragment(text)), true
+	}
+	return b.lexicon.TextToSSML(text), true
+}
+
+// PrepareSSML applies the lexicon to an SSML document
+func (b *BaseProvider) PrepareSSML(ssml string) string {
+	if b.lexicon == nil || len(b.lexicon.Entries) == 0 {
+		return ssml
+	}
+	return b.lexicon.ApplySSML(ssml)
+}
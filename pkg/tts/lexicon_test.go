@@ -0,0 +1,156 @@
+package tts_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+const testPLS = `<?xml version="1.0" encoding="UTF-8"?>
+<lexicon version="1.0" xmlns="http://www.w3.org/2005/01/pronunciation-lexicon"
+      alphabet="ipa" xml:lang="en-US">
+  <lexeme>
+    <grapheme>Siobhan</grapheme>
+    <phoneme>ʃɪˈvɔːn</phoneme>
+  </lexeme>
+  <lexeme>
+    <grapheme>W3C</grapheme>
+    <alias>World Wide Web Consortium</alias>
+  </lexeme>
+</lexicon>`
+
+func TestLoadPLS(t *testing.T) {
+	lex, err := tts.LoadPLS(strings.NewReader(testPLS))
+	if err != nil {
+		t.Fatalf("LoadPLS failed: %v", err)
+	}
+
+	if lex.Language != "en-US" {
+		t.Errorf("Expected language en-US, got %q", lex.Language)
+	}
+	if lex.Alphabet != "ipa" {
+		t.Errorf("Expected alphabet ipa, got %q", lex.Alphabet)
+	}
+	if len(lex.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(lex.Entries))
+	}
+	if lex.Entries[0].Phoneme != "ʃɪˈvɔːn" {
+		t.Errorf("Unexpected phoneme: %q", lex.Entries[0].Phoneme)
+	}
+	if lex.Entries[1].Alias != "World Wide Web Consortium" {
+		t.Errorf("Unexpected alias: %q", lex.Entries[1].Alias)
+	}
+
+	var buf bytes.Buffer
+	if err := lex.WritePLS(&buf); err != nil {
+		t.Fatalf("WritePLS failed: %v", err)
+	}
+	roundTrip, err := tts.LoadPLS(&buf)
+	if err != nil {
+		t.Fatalf("LoadPLS of written lexicon failed: %v", err)
+	}
+	if len(roundTrip.Entries) != 2 || roundTrip.Language != "en-US" {
+		t.Errorf("Round trip lost data: %+v", roundTrip)
+	}
+}
+
+func TestLoadLexiconCSV(t *testing.T) {
+	input := "grapheme,phoneme,alias\nNguyen,ŋwɪən,\nSQL,,sequel\n"
+	lex, err := tts.LoadLexiconCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LoadLexiconCSV failed: %v", err)
+	}
+	if len(lex.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(lex.Entries))
+	}
+
+	if _, err := tts.LoadLexiconCSV(strings.NewReader("lonely\n")); err == nil {
+		t.Error("Expected error for row without phoneme column")
+	}
+}
+
+func TestLexiconApply(t *testing.T) {
+	lex := tts.NewLexicon("test", "en-US", "ipa")
+	lex.Add("Siobhan", "ʃɪˈvɔːn", "")
+	lex.Add("SQL", "", "sequel")
+	lex.Add("AT&T", "", "A T and T")
+
+	testCases := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "text aliases",
+			got:  lex.ApplyText("Siobhan knows SQL, not SQLite."),
+			want: "Siobhan knows sequel, not SQLite.",
+		},
+		{
+			name: "text to SSML",
+			got:  lex.TextToSSML("Ask siobhan about AT&T"),
+			want: `<speak>Ask <phoneme alphabet="ipa" ph="ʃɪˈvɔːn">siobhan</phoneme> about <sub alias="A T and T">AT&amp;T</sub></speak>`,
+		},
+		{
+			name: "existing SSML",
+			got:  lex.ApplySSML(`<speak><sub alias="S Q L">SQL</sub> and <break time="1s"/>SQL</speak>`),
+			want: `<speak><sub alias="S Q L">SQL</sub> and <break time="1s"/><sub alias="sequel">SQL</sub></speak>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.got != tc.want {
+				t.Errorf("got  %s\nwant %s", tc.got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBaseProviderPrepareText(t *testing.T) {
+	base := tts.NewBaseProvider(tts.TTSConfig{})
+
+	if text, isSSML := base.PrepareText("SQL", true); isSSML || text != "SQL" {
+		t.Errorf("Expected text unchanged without lexicon, got %q (ssml=%v)", text, isSSML)
+	}
+
+	lex := tts.NewLexicon("test", "en-US", "ipa")
+	lex.Add("SQL", "", "sequel")
+	if err := base.SetProperty("lexicon", lex); err != nil {
+		t.Fatalf("SetProperty(lexicon) failed: %v", err)
+	}
+
+	if text, isSSML := base.PrepareText("SQL", false); isSSML || text != "sequel" {
+		t.Errorf("Expected alias substitution, got %q (ssml=%v)", text, isSSML)
+	}
+	if text, isSSML := base.PrepareText("SQL", true); !isSSML || !strings.HasPrefix(text, "<speak>") {
+		t.Errorf("Expected SSML output, got %q (ssml=%v)", text, isSSML)
+	}
+
+	base.SetSSMLEnvelope(func(body string) string {
+		return `<speak version="1.0"><voice name="test">` + body + "</voice></speak>"
+	})
+	want := `<speak version="1.0"><voice name="test"><sub alias="sequel">SQL</sub></voice></speak>`
+	if text, isSSML := base.PrepareText("SQL", true); !isSSML || text != want {
+		t.Errorf("Expected the provider's envelope, got %q (ssml=%v)", text, isSSML)
+	}
+}
+
+func TestLexiconSharedAcrossGoroutines(t *testing.T) {
+	lex := tts.NewLexicon("test", "en-US", "ipa")
+	lex.Add("SQL", "", "sequel")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := lex.ApplyText("SQL"); got != "sequel" {
+				t.Errorf("ApplyText() = %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
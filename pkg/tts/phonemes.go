@@ -0,0 +1,122 @@
+package tts
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PhonemizedWord is the pronunciation of a single word of the input text
+type PhonemizedWord struct {
+	Word        string // Word as written in the input
+	Offset      int    // Byte offset of the word in the input
+	IPA         string
+	XSAMPA      string
+	Kirshenbaum string
+}
+
+// Phonemizer is implemented by providers that can convert text to phonemes
+type Phonemizer interface {
+	Phonemize(ctx context.Context, text, lang string) ([]PhonemizedWord, error)
+}
+
+// ipaToXSAMPA maps IPA symbols to X-SAMPA
+var ipaToXSAMPA = map[string]string{
+	// Vowels
+	"ɨ": "1", "ʉ": "}", "ɯ": "M", "ɪ": "I", "ʏ": "Y", "ʊ": "U", "ø": "2",
+	"ɘ": "@\\", "ɵ": "8", "ɤ": "7", "ə": "@", "ɛ": "E", "œ": "9", "ɜ": "3",
+	"ɞ": "3\\", "ʌ": "V", "ɔ": "O", "æ": "{", "ɐ": "6", "ɶ": "&", "ɑ": "A",
+	"ɒ": "Q", "ɚ": "@`", "ɝ": "3`",
+	// Consonants
+	"ʈ": "t`", "ɖ": "d`", "ɟ": "J\\", "ɡ": "g", "ɢ": "G\\", "ʔ": "?",
+	"ɱ": "F", "ɳ": "n`", "ɲ": "J", "ŋ": "N", "ɴ": "N\\", "ʙ": "B\\",
+	"ʀ": "R\\", "ɾ": "4", "ɽ": "r`", "ɸ": "p\\", "β": "B", "θ": "T",
+	"ð": "D", "ʃ": "S", "ʒ": "Z", "ʂ": "s`", "ʐ": "z`", "ç": "C",
+	"ʝ": "j\\", "ɣ": "G", "χ": "X", "ʁ": "R", "ħ": "X\\", "ʕ": "?\\",
+	"ɦ": "h\\", "ɬ": "K", "ɮ": "K\\", "ʋ": "P", "ɹ": "r\\", "ɻ": "r\\`",
+	"ɰ": "M\\", "ɭ": "l`", "ʎ": "L", "ʟ": "L\\", "ʍ": "W", "ɥ": "H",
+	"ʜ": "H\\", "ɫ": "5", "ɕ": "s\\", "ʑ": "z\\", "ɺ": "l\\",
+	"tʃ": "tS", "dʒ": "dZ",
+	// Suprasegmentals and diacritics
+	"ˈ": "\"", "ˌ": "%", "ː": ":", "ˑ": ":\\", "̃": "~", "ʰ": "_h",
+	"ʲ": "'", "ʷ": "_w", "̩": "=", "‿": "-\\", "͡": "",
+}
+
+// ipaToKirshenbaum maps IPA symbols to Kirshenbaum ASCII-IPA
+var ipaToKirshenbaum = map[string]string{
+	// Vowels
+	"ɨ": "i\"", "ʉ": "u\"", "ɯ": "u-", "ɪ": "I", "ʏ": "I.", "ʊ": "U",
+	"ø": "Y", "ɵ": "@.", "ɤ": "o-", "ə": "@", "ɛ": "E", "œ": "W",
+	"ɜ": "V\"", "ʌ": "V", "ɔ": "O", "æ": "&", "ɐ": "a#", "ɑ": "A",
+	"ɒ": "A.", "ɚ": "R", "ɝ": "R<umd>",
+	// Consonants
+	"ʈ": "t.", "ɖ": "d.", "ɟ": "J", "ɡ": "g", "ɢ": "G", "ʔ": "?",
+	"ɱ": "M", "ɳ": "n.", "ɲ": "n^", "ŋ": "N", "ɴ": "n\"", "ʀ": "r\"",
+	"ɾ": "*", "ɽ": "*.", "ɸ": "P", "β": "B", "θ": "T", "ð": "D",
+	"ʃ": "S", "ʒ": "Z", "ʂ": "s.", "ʐ": "z.", "ç": "C", "ʝ": "C<vcd>",
+	"ɣ": "Q", "χ": "X", "ʁ": "g\"", "ħ": "H", "ʕ": "H<vcd>", "ɦ": "h<?>",
+	"ɬ": "s<lat>", "ɮ": "z<lat>", "ʋ": "r<lbd>", "ɹ": "r", "ɻ": "r.",
+	"ɰ": "j<vel>", "ɭ": "l.", "ʎ": "l^", "ʟ": "L", "ʍ": "w<vls>",
+	"ɥ": "w<pal>", "ɫ": "l<vel>",
+	// Suprasegmentals and diacritics
+	"ˈ": "'", "ˌ": ",", "ː": ":", "̃": "~", "ʰ": "<h>", "ʲ": ";",
+	"͡": "",
+}
+
+var (
+	xsampaTransliterator      = newTransliterator(ipaToXSAMPA)
+	kirshenbaumTransliterator = newTransliterator(ipaToKirshenbaum)
+)
+
+// IPAToXSAMPA transliterates an IPA string to X-SAMPA.
+// Symbols without a mapping are passed through unchanged.
+func IPAToXSAMPA(ipa string) string {
+	return xsampaTransliterator.convert(ipa)
+}
+
+// IPAToKirshenbaum transliterates an IPA string to Kirshenbaum ASCII-IPA.
+// Symbols without a mapping are passed through unchanged.
+func IPAToKirshenbaum(ipa string) string {
+	return kirshenbaumTransliterator.convert(ipa)
+}
+
+type transliterator struct {
+	table map[string]string
+	keys  []string // longest first so affricates win over their parts
+}
+
+func newTransliterator(table map[string]string) *transliterator {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return &transliterator{table: table, keys: keys}
+}
+
+func (t *transliterator) convert(s string) string {
+	var out strings.Builder
+	for len(s) > 0 {
+		matched := false
+		for _, k := range t.keys {
+			if strings.HasPrefix(s, k) {
+				out.WriteString(t.table[k])
+				s = s[len(k):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			_, size := utf8.DecodeRuneInString(s)
+			out.WriteString(s[:size])
+			s = s[size:]
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,32 @@
+package tts_test
+
+import (
+	"testing"
+
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func TestIPATransliteration(t *testing.T) {
+	testCases := []struct {
+		ipa         string
+		xsampa      string
+		kirshenbaum string
+	}{
+		{"həlˈoʊ", `h@l"oU`, "h@l'oU"},
+		{"ðɪs", "DIs", "DIs"},
+		{"tʃˈɜːtʃ", `tS"3:tS`, "tS'V\":tS"},
+		{"sˈɪŋɪŋ", `s"ININ`, "s'ININ"},
+		{"kæt", "k{t", "k&t"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ipa, func(t *testing.T) {
+			if got := tts.IPAToXSAMPA(tc.ipa); got != tc.xsampa {
+				t.Errorf("IPAToXSAMPA(%q) = %q, want %q", tc.ipa, got, tc.xsampa)
+			}
+			if got := tts.IPAToKirshenbaum(tc.ipa); got != tc.kirshenbaum {
+				t.Errorf("IPAToKirshenbaum(%q) = %q, want %q", tc.ipa, got, tc.kirshenbaum)
+			}
+		})
+	}
+}
@@ -3,21 +3,61 @@ package tts
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/polly"
 	"github.com/aws/aws-sdk-go-v2/service/polly/types"
 	tts "github.com/willwade/go-tts-wrapper"
 )
 
+// pollyClient is the part of the Polly API the provider uses
+type pollyClient interface {
+	DescribeVoices(ctx context.Context, params *polly.DescribeVoicesInput, optFns ...func(*polly.Options)) (*polly.DescribeVoicesOutput, error)
+	PutLexicon(ctx context.Context, params *polly.PutLexiconInput, optFns ...func(*polly.Options)) (*polly.PutLexiconOutput, error)
+	SynthesizeSpeech(ctx context.Context, params *polly.SynthesizeSpeechInput, optFns ...func(*polly.Options)) (*polly.SynthesizeSpeechOutput, error)
+}
+
 type PollyProvider struct {
 	*tts.BaseProvider
-	client      *polly.Client
-	audioPlayer *tts.AudioPlayer
+	client       pollyClient
+	audioPlayer  *tts.AudioPlayer
+	engine       types.Engine // empty to pick the best engine the voice supports
+	lexiconNames []string
+
+	enginesMu    sync.Mutex
+	voiceEngines map[string][]types.Engine // supported engines by voice ID, loaded on first use
 }
 
+// Engines added to Polly after the SDK version this module uses
+const (
+	pollyEngineLongForm   types.Engine = "long-form"
+	pollyEngineGenerative types.Engine = "generative"
+)
+
+// pollyEnginePreference is the order engines are chosen in when the
+// configuration does not name one
+var pollyEnginePreference = []types.Engine{
+	types.EngineNeural,
+	pollyEngineGenerative,
+	pollyEngineLongForm,
+	types.EngineStandard,
+}
+
+// pollySampleRates are the rates Polly can produce MP3 audio at
+var pollySampleRates = []int{8000, 16000, 22050, 24000}
+
+// maxPollyLexicons is the number of lexicons Polly applies to one request
+const maxPollyLexicons = 5
+
 func NewPollyProvider(cfg tts.TTSConfig) (*PollyProvider, error) {
 	// Load AWS configuration
 	awsCfg, err := config.LoadDefaultConfig(context.Background(),
@@ -30,6 +70,11 @@ func NewPollyProvider(cfg tts.TTSConfig) (*PollyProvider, error) {
 	// Create Polly client
 	client := polly.NewFromConfig(awsCfg)
 
+	engine, err := parsePollyEngine(cfg.Engine)
+	if err != nil {
+		return nil, err
+	}
+
 	audioPlayer, err := tts.NewAudioPlayer()
 	if err != nil {
 		return nil, err
@@ -37,23 +82,146 @@ func NewPollyProvider(cfg tts.TTSConfig) (*PollyProvider, error) {
 
 	return &PollyProvider{
 		BaseProvider: tts.NewBaseProvider(cfg),
-		client:      client,
-		audioPlayer: audioPlayer,
+		client:       client,
+		audioPlayer:  audioPlayer,
+		engine:       engine,
 	}, nil
 }
 
-func (p *PollyProvider) synthesize(ctx context.Context, text string, isSSML bool) ([]byte, error) {
+// parsePollyEngine checks an engine name; an empty name selects the engine
+// from the voice
+func parsePollyEngine(name string) (types.Engine, error) {
+	if name == "" {
+		return "", nil
+	}
+	for _, engine := range pollyEnginePreference {
+		if strings.EqualFold(name, string(engine)) {
+			return engine, nil
+		}
+	}
+	return "", fmt.Errorf("unknown Polly engine %q, expected one of %v", name, pollyEnginePreference)
+}
+
+// SetProperty handles the Polly-specific "engine" (string) and "lexicons"
+// ([]string, names of lexicons stored in Polly) properties and passes the
+// rest to BaseProvider
+func (p *PollyProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "engine":
+		if name, ok := value.(string); ok {
+			engine, err := parsePollyEngine(name)
+			if err != nil {
+				return err
+			}
+			p.engine = engine
+			return nil
+		}
+		return fmt.Errorf("invalid property or value type: %s", property)
+	case "lexicons":
+		if names, ok := value.([]string); ok {
+			if len(names) > maxPollyLexicons {
+				return fmt.Errorf("at most %d lexicons can be applied to a Polly request, got %d", maxPollyLexicons, len(names))
+			}
+			p.lexiconNames = append([]string(nil), names...)
+			return nil
+		}
+		return fmt.Errorf("invalid property or value type: %s", property)
+	}
+	return p.BaseProvider.SetProperty(property, value)
+}
+
+// engineFor returns the configured engine, or the preferred engine among
+// those the voice supports
+func (p *PollyProvider) engineFor(ctx context.Context, voiceID string) (types.Engine, error) {
+	if p.engine != "" {
+		return p.engine, nil
+	}
+
+	p.enginesMu.Lock()
+	defer p.enginesMu.Unlock()
+	if p.voiceEngines == nil {
+		engines := make(map[string][]types.Engine)
+		input := &polly.DescribeVoicesInput{}
+		for {
+			resp, err := p.client.DescribeVoices(ctx, input)
+			if err != nil {
+				return "", fmt.Errorf("failed to look up voice engines: %w", err)
+			}
+			for _, v := range resp.Voices {
+				engines[string(v.Id)] = v.SupportedEngines
+			}
+			if resp.NextToken == nil {
+				break
+			}
+			input.NextToken = resp.NextToken
+		}
+		p.voiceEngines = engines
+	}
+
+	supported, ok := p.voiceEngines[voiceID]
+	if !ok {
+		return "", fmt.Errorf("unknown Polly voice %q", voiceID)
+	}
+	for _, preferred := range pollyEnginePreference {
+		for _, engine := range supported {
+			if engine == preferred {
+				return engine, nil
+			}
+		}
+	}
+	if len(supported) > 0 {
+		return supported[0], nil
+	}
+	return "", fmt.Errorf("voice %q supports no Polly engines", voiceID)
+}
+
+// pollySampleRate returns the rate to request from Polly for the configured
+// output rate: the lowest supported rate at or above it, so the player only
+// ever downsamples. Zero leaves the engine's default.
+func pollySampleRate(rate int) *string {
+	if rate <= 0 {
+		return nil
+	}
+	chosen := pollySampleRates[len(pollySampleRates)-1]
+	for _, supported := range pollySampleRates {
+		if supported >= rate {
+			chosen = supported
+			break
+		}
+	}
+	return aws.String(strconv.Itoa(chosen))
+}
+
+// newInput builds a synthesis request for text with the current voice,
+// engine, sample rate and lexicons
+func (p *PollyProvider) newInput(ctx context.Context, text string, isSSML bool, format types.OutputFormat) (*polly.SynthesizeSpeechInput, error) {
 	inputType := types.TextTypeText
 	if isSSML {
 		inputType = types.TextTypeSsml
 	}
+	engine, err := p.engineFor(ctx, p.config.VoiceID)
+	if err != nil {
+		return nil, err
+	}
 
 	input := &polly.SynthesizeSpeechInput{
-		Engine:       types.EngineNeural,
-		OutputFormat: types.OutputFormatMp3,
+		Engine:       engine,
+		OutputFormat: format,
 		Text:         &text,
 		TextType:     inputType,
 		VoiceId:      types.VoiceId(p.config.VoiceID),
+		LexiconNames: p.lexiconNames,
+	}
+	if format == types.OutputFormatMp3 {
+		input.SampleRate = pollySampleRate(p.audioConfig.SampleRate)
+	}
+	return input, nil
+}
+
+func (p *PollyProvider) synthesize(ctx context.Context, text string, isSSML bool) ([]byte, error) {
+	input, err := p.newInput(ctx, text, isSSML, types.OutputFormatMp3)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := p.client.SynthesizeSpeech(ctx, input)
@@ -65,45 +233,227 @@ func (p *PollyProvider) synthesize(ctx context.Context, text string, isSSML bool
 	return io.ReadAll(resp.AudioStream)
 }
 
+// pollyMarkTypes are the speech marks Polly can return and the events they fire
+var pollyMarkTypes = []struct {
+	markType types.SpeechMarkType
+	event    string
+}{
+	{types.SpeechMarkTypeWord, tts.EventWord},
+	{types.SpeechMarkTypeSentence, tts.EventSentence},
+	{types.SpeechMarkTypeViseme, tts.EventViseme},
+	{types.SpeechMarkTypeSsml, tts.EventMark},
+}
+
+// pollyTimingKinds maps speech mark types to timing kinds
+var pollyTimingKinds = map[string]tts.TimingKind{
+	"word":     tts.WordTiming,
+	"sentence": tts.SentenceTiming,
+	"viseme":   tts.VisemeTiming,
+	"ssml":     tts.MarkTiming,
+}
+
+// pollySpeechMark is one line of Polly's speech mark output
+type pollySpeechMark struct {
+	Time  int64  `json:"time"` // milliseconds from the start of the audio
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// synthesizeWithMarks requests the audio and, if markTypes is not empty,
+// the speech marks for text. Polly returns marks in a separate call, which
+// runs concurrently with the audio request.
+func (p *PollyProvider) synthesizeWithMarks(ctx context.Context, text string, isSSML bool, markTypes []types.SpeechMarkType) ([]byte, []tts.Timing, error) {
+	if len(markTypes) == 0 {
+		audioData, err := p.synthesize(ctx, text, isSSML)
+		return audioData, nil, err
+	}
+
+	type result struct {
+		marks []tts.Timing
+		err   error
+	}
+	marksDone := make(chan result, 1)
+	go func() {
+		marks, err := p.speechMarks(ctx, text, isSSML, markTypes)
+		marksDone <- result{marks, err}
+	}()
+
+	audioData, err := p.synthesize(ctx, text, isSSML)
+	marks := <-marksDone
+	if err != nil {
+		return nil, nil, err
+	}
+	if marks.err != nil {
+		return nil, nil, marks.err
+	}
+	return audioData, marks.marks, nil
+}
+
+// speechMarks requests the given speech marks for text
+func (p *PollyProvider) speechMarks(ctx context.Context, text string, isSSML bool, markTypes []types.SpeechMarkType) ([]tts.Timing, error) {
+	input, err := p.newInput(ctx, text, isSSML, types.OutputFormatJson)
+	if err != nil {
+		return nil, err
+	}
+	input.SpeechMarkTypes = markTypes
+
+	resp, err := p.client.SynthesizeSpeech(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get speech marks: %w", err)
+	}
+	defer resp.AudioStream.Close()
+	return parsePollySpeechMarks(resp.AudioStream)
+}
+
+// parsePollySpeechMarks decodes newline-delimited speech marks. Polly gives
+// only start times, so the timings have no end.
+func parsePollySpeechMarks(r io.Reader) ([]tts.Timing, error) {
+	var timings []tts.Timing
+	dec := json.NewDecoder(r)
+	for {
+		var mark pollySpeechMark
+		if err := dec.Decode(&mark); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse speech marks: %w", err)
+		}
+		kind, ok := pollyTimingKinds[mark.Type]
+		if !ok {
+			continue
+		}
+		timings = append(timings, tts.Timing{
+			Kind:  kind,
+			Text:  mark.Value,
+			Start: time.Duration(mark.Time) * time.Millisecond,
+		})
+	}
+	return timings, nil
+}
+
 func (p *PollyProvider) Speak(ctx context.Context, text string) error {
-	audioData, err := p.synthesize(ctx, text, false)
+	text, isSSML := p.PrepareText(text, true)
+	return p.play(ctx, text, isSSML)
+}
+
+func (p *PollyProvider) SpeakSSML(ctx context.Context, ssml string) error {
+	if err := p.ValidateSSML(ssml); err != nil {
+		return err
+	}
+	return p.play(ctx, p.PrepareSSML(ssml), true)
+}
+
+// play synthesizes text with the speech marks connected callbacks need and
+// plays it, firing the marks as playback reaches them
+func (p *PollyProvider) play(ctx context.Context, text string, isSSML bool) error {
+	var markTypes []types.SpeechMarkType
+	for _, mt := range pollyMarkTypes {
+		if p.Listening(mt.event) && (isSSML || mt.markType != types.SpeechMarkTypeSsml) {
+			markTypes = append(markTypes, mt.markType)
+		}
+	}
+
+	audioData, marks, err := p.synthesizeWithMarks(ctx, text, isSSML, markTypes)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+	if err := p.PrepareAudio(p.audioPlayer); err != nil {
+		return err
+	}
+	tts.FillTimingEnds(marks, 0)
+	return p.PlayWithEvents(p.audioPlayer, bytes.NewReader(audioData), marks)
 }
 
-func (p *PollyProvider) SpeakSSML(ctx context.Context, ssml string) error {
+// SpeakStreamed writes the synthesized audio to w instead of playing it
+func (p *PollyProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, isSSML := p.PrepareText(text, true)
+	audioData, err := p.synthesize(ctx, text, isSSML)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SpeakSSMLStreamed writes the synthesized SSML audio to w instead of playing it
+func (p *PollyProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
 	if err := p.ValidateSSML(ssml); err != nil {
 		return err
 	}
-	audioData, err := p.synthesize(ctx, ssml, true)
+	audioData, err := p.synthesize(ctx, p.PrepareSSML(ssml), true)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *PollyProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthSSMLToFile synthesizes SSML and writes the audio to filename
+func (p *PollyProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakSSMLStreamed(ctx, ssml, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthToFileWithTimings synthesizes text to filename and returns its word
+// and sentence timings from Polly's speech marks
+func (p *PollyProvider) SynthToFileWithTimings(ctx context.Context, text, filename string) ([]tts.Timing, error) {
+	text, isSSML := p.PrepareText(text, true)
+	markTypes := []types.SpeechMarkType{types.SpeechMarkTypeWord, types.SpeechMarkTypeSentence}
+	audioData, marks, err := p.synthesizeWithMarks(ctx, text, isSSML, markTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last word and sentence end with the audio
+	total, err := tts.AudioDuration(bytes.NewReader(audioData))
+	if err != nil {
+		return nil, err
+	}
+	tts.FillTimingEnds(marks, total)
+
+	if err := p.WriteAudioFile(filename, bytes.NewReader(audioData)); err != nil {
+		return nil, err
+	}
+	return marks, nil
 }
 
 func (p *PollyProvider) GetVoices(ctx context.Context) ([]tts.Voice, error) {
+	// Without a configured engine, list voices for every engine
 	input := &polly.DescribeVoicesInput{
-		Engine: types.EngineNeural,
+		Engine: p.engine,
 	}
 
-	resp, err := p.client.DescribeVoices(ctx, input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get voices: %w", err)
-	}
-
-	voices := make([]tts.Voice, 0, len(resp.Voices))
-	for _, v := range resp.Voices {
-		voices = append(voices, tts.Voice{
-			ID:          string(v.Id),
-			Name:        *v.Name,
-			Language:    *v.LanguageCode,
-			Gender:      string(v.Gender),
-			Provider:    "AWS Polly",
-			NativeVoice: v,
-		})
+	var voices []tts.Voice
+	for {
+		resp, err := p.client.DescribeVoices(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get voices: %w", err)
+		}
+		for _, v := range resp.Voices {
+			voices = append(voices, tts.Voice{
+				ID:          string(v.Id),
+				Name:        *v.Name,
+				Language:    *v.LanguageCode,
+				Gender:      string(v.Gender),
+				Provider:    "AWS Polly",
+				NativeVoice: v,
+			})
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
 	}
 
 	return voices, nil
@@ -122,6 +472,79 @@ func (p *PollyProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *PollyProvider) SetAudioSink(sink tts.AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
+func (p *PollyProvider) SetOutputDevice(deviceID string) error {
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *PollyProvider) Player() *tts.AudioPlayer {
+	return p.audioPlayer
+}
+
+// UploadLexicon stores the lexicon in Polly with PutLexicon and applies it
+// to all subsequent synthesis requests
+func (p *PollyProvider) UploadLexicon(ctx context.Context, lexicon *tts.Lexicon) error {
+	// Copy the fields the PLS document needs, leaving the lexicon's lock alone
+	lex := &tts.Lexicon{
+		Name:          lexicon.Name,
+		Language:      lexicon.Language,
+		Alphabet:      lexicon.Alphabet,
+		CaseSensitive: lexicon.CaseSensitive,
+		Entries:       lexicon.Entries,
+	}
+	if lex.Language == "" {
+		lex.Language = p.config.LanguageCode
+	}
+
+	var content strings.Builder
+	if err := lex.WritePLS(&content); err != nil {
+		return err
+	}
+
+	name := pollyLexiconName(lex.Name)
+	_, err := p.client.PutLexicon(ctx, &polly.PutLexiconInput{
+		Name:    aws.String(name),
+		Content: aws.String(content.String()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload lexicon: %w", err)
+	}
+
+	for _, existing := range p.lexiconNames {
+		if existing == name {
+			return nil
+		}
+	}
+	if len(p.lexiconNames) == maxPollyLexicons {
+		return fmt.Errorf("lexicon %q was stored but not applied: at most %d lexicons can be applied to a Polly request", name, maxPollyLexicons)
+	}
+	p.lexiconNames = append(p.lexiconNames, name)
+	return nil
+}
+
+// pollyLexiconName reduces a name to the 1-20 alphanumeric characters Polly accepts
+func pollyLexiconName(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, name)
+	if cleaned == "" {
+		cleaned = "lexicon"
+	}
+	if len(cleaned) > 20 {
+		cleaned = cleaned[:20]
+	}
+	return cleaned
+}
+
 func (p *PollyProvider) CheckCredentials(ctx context.Context) bool {
 	_, err := p.client.DescribeVoices(ctx, &polly.DescribeVoicesInput{})
 	return err == nil
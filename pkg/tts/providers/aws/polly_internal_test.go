@@ -0,0 +1,115 @@
+package tts
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+	"github.com/aws/aws-sdk-go-v2/service/polly/types"
+	tts "github.com/willwade/go-tts-wrapper"
+)
+
+func TestParsePollySpeechMarks(t *testing.T) {
+	payload := `{"time":6,"type":"sentence","start":0,"end":23,"value":"Mary had a little lamb."}
+{"time":6,"type":"word","start":0,"end":4,"value":"Mary"}
+{"time":6,"type":"viseme","value":"p"}
+{"time":373,"type":"word","start":5,"end":8,"value":"had"}
+{"time":604,"type":"ssml","start":9,"end":30,"value":"here"}
+{"time":604,"type":"word","start":31,"end":35,"value":"lamb"}
+{"time":700,"type":"unknown","value":"skipped"}
+`
+	timings, err := parsePollySpeechMarks(strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tts.FillTimingEnds(timings, 1500*time.Millisecond)
+
+	want := []tts.Timing{
+		{Kind: tts.SentenceTiming, Text: "Mary had a little lamb.", Start: 6 * time.Millisecond, End: 1500 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "Mary", Start: 6 * time.Millisecond, End: 373 * time.Millisecond},
+		{Kind: tts.VisemeTiming, Text: "p", Start: 6 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "had", Start: 373 * time.Millisecond, End: 604 * time.Millisecond},
+		{Kind: tts.MarkTiming, Text: "here", Start: 604 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "lamb", Start: 604 * time.Millisecond, End: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(timings, want) {
+		t.Errorf("timings = %v, want %v", timings, want)
+	}
+
+	if _, err := parsePollySpeechMarks(strings.NewReader(`{"time":6,"type":`)); err == nil {
+		t.Error("Expected an error for truncated speech marks")
+	}
+}
+
+// stubPollyClient serves DescribeVoices from pages of voices
+type stubPollyClient struct {
+	pollyClient
+	pages [][]types.Voice
+	calls int
+}
+
+func (c *stubPollyClient) DescribeVoices(ctx context.Context, params *polly.DescribeVoicesInput, optFns ...func(*polly.Options)) (*polly.DescribeVoicesOutput, error) {
+	c.calls++
+	page := 0
+	if params.NextToken != nil {
+		page = len(*params.NextToken)
+	}
+	out := &polly.DescribeVoicesOutput{Voices: c.pages[page]}
+	if page+1 < len(c.pages) {
+		out.NextToken = aws.String(strings.Repeat("x", page+1))
+	}
+	return out, nil
+}
+
+func TestEngineFor(t *testing.T) {
+	client := &stubPollyClient{pages: [][]types.Voice{
+		{
+			{Id: "Joanna", SupportedEngines: []types.Engine{types.EngineStandard, types.EngineNeural}},
+			{Id: "Matthew", SupportedEngines: []types.Engine{types.EngineStandard}},
+		},
+		{
+			{Id: "Ruth", SupportedEngines: []types.Engine{pollyEngineLongForm, pollyEngineGenerative}},
+			{Id: "Silent"},
+		},
+	}}
+	p := &PollyProvider{client: client}
+
+	tests := []struct {
+		voice string
+		want  types.Engine
+		err   bool
+	}{
+		{voice: "Joanna", want: types.EngineNeural},
+		{voice: "Matthew", want: types.EngineStandard},
+		{voice: "Ruth", want: pollyEngineGenerative},
+		{voice: "Silent", err: true},
+		{voice: "Nobody", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.voice, func(t *testing.T) {
+			got, err := p.engineFor(context.Background(), tc.voice)
+			if tc.err {
+				if err == nil {
+					t.Errorf("engineFor(%q) = %q, want an error", tc.voice, got)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("engineFor(%q) = %q, %v, want %q", tc.voice, got, err, tc.want)
+			}
+		})
+	}
+	if client.calls != 2 {
+		t.Errorf("Expected the voice list to be fetched once in 2 pages, got %d calls", client.calls)
+	}
+
+	// A configured engine is used without looking the voice up
+	p = &PollyProvider{client: &stubPollyClient{}, engine: types.EngineStandard}
+	if got, err := p.engineFor(context.Background(), "Joanna"); err != nil || got != types.EngineStandard {
+		t.Errorf("engineFor with a configured engine = %q, %v, want standard", got, err)
+	}
+}
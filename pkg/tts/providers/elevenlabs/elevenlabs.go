@@ -3,24 +3,63 @@ package tts
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
 )
 
 const elevenLabsBaseURL = "https://api.elevenlabs.io/v1"
 
+// defaultElevenLabsFormat is the output format used unless one is configured
+const defaultElevenLabsFormat = "mp3_44100_128"
+
 // ElevenLabsProvider implements TTSProvider for ElevenLabs
 type ElevenLabsProvider struct {
 	*BaseProvider
-	apiKey      string
-	client      *http.Client
-	audioPlayer *AudioPlayer
+	apiKey       string
+	client       *http.Client
+	audioPlayer  *AudioPlayer
+	baseURL      string // API root, replaced in tests
+	modelID      string // e.g. "eleven_multilingual_v2"; empty uses the account default
+	settings     ElevenLabsVoiceSettings
+	outputFormat string // codec_rate[_bitrate], e.g. "mp3_44100_128" or "pcm_16000"
+}
+
+// ElevenLabsVoiceSettings control how a voice sounds
+type ElevenLabsVoiceSettings struct {
+	Stability       float64 `json:"stability"`        // 0-1; lower is more expressive
+	SimilarityBoost float64 `json:"similarity_boost"` // 0-1; how closely to match the original voice
+	Style           float64 `json:"style"`            // 0-1; style exaggeration, on models that support it
+	UseSpeakerBoost bool    `json:"use_speaker_boost"`
 }
 
-// NewElevenLabsProvider creates a new ElevenLabs provider
+// DefaultElevenLabsVoiceSettings are the settings used unless others are set
+var DefaultElevenLabsVoiceSettings = ElevenLabsVoiceSettings{
+	Stability:       0.75,
+	SimilarityBoost: 0.75,
+	UseSpeakerBoost: true,
+}
+
+// NewElevenLabsProvider creates a new ElevenLabs provider. TTSConfig.Engine
+// selects the model and TTSConfig.OutputFormat the output format.
 func NewElevenLabsProvider(cfg TTSConfig) (*ElevenLabsProvider, error) {
+	format := cfg.OutputFormat
+	if format == "" {
+		format = defaultElevenLabsFormat
+	}
+	if _, _, err := parseElevenLabsFormat(format); err != nil {
+		return nil, err
+	}
+
 	audioPlayer, err := NewAudioPlayer()
 	if err != nil {
 		return nil, err
@@ -28,34 +67,110 @@ func NewElevenLabsProvider(cfg TTSConfig) (*ElevenLabsProvider, error) {
 
 	return &ElevenLabsProvider{
 		BaseProvider: NewBaseProvider(cfg),
-		apiKey:      cfg.APIKey,
-		client:      &http.Client{},
-		audioPlayer: audioPlayer,
+		apiKey:       cfg.APIKey,
+		client:       &http.Client{},
+		baseURL:      elevenLabsBaseURL,
+		audioPlayer:  audioPlayer,
+		modelID:      cfg.Engine,
+		settings:     DefaultElevenLabsVoiceSettings,
+		outputFormat: format,
 	}, nil
 }
 
-type synthesisRequest struct {
-	Text     string           `json:"text"`
-	ModelID  string           `json:"model_id,omitempty"`
-	VoiceSettings voiceSettings `json:"voice_settings,omitempty"`
+// parseElevenLabsFormat splits an output format such as "mp3_44100_128"
+// into its codec and sample rate
+func parseElevenLabsFormat(format string) (codec string, sampleRate int, err error) {
+	parts := strings.Split(format, "_")
+	if len(parts) >= 2 {
+		if rate, convErr := strconv.Atoi(parts[1]); convErr == nil {
+			switch parts[0] {
+			case "opus":
+				if !OpusSupported() {
+					return "", 0, fmt.Errorf("output format %q needs a build with the opus tag; use an MP3 or PCM format", format)
+				}
+				return parts[0], rate, nil
+			case "mp3", "pcm", "ulaw", "alaw":
+				return parts[0], rate, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("unsupported ElevenLabs output format %q", format)
+}
+
+// SetProperty handles the ElevenLabs-specific "model" (string),
+// "stability", "similarity_boost" and "style" (float64 from 0 to 1),
+// "speaker_boost" (bool) and "output_format" (string) properties and
+// passes the rest to BaseProvider
+func (p *ElevenLabsProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "model":
+		if model, ok := value.(string); ok {
+			p.modelID = model
+			return nil
+		}
+	case "stability", "similarity_boost", "style":
+		if v, ok := value.(float64); ok {
+			if v < 0 || v > 1 {
+				return fmt.Errorf("%s must be between 0 and 1, got %v", property, v)
+			}
+			switch property {
+			case "stability":
+				p.settings.Stability = v
+			case "similarity_boost":
+				p.settings.SimilarityBoost = v
+			default:
+				p.settings.Style = v
+			}
+			return nil
+		}
+	case "speaker_boost":
+		if boost, ok := value.(bool); ok {
+			p.settings.UseSpeakerBoost = boost
+			return nil
+		}
+	case "output_format":
+		if format, ok := value.(string); ok {
+			if _, _, err := parseElevenLabsFormat(format); err != nil {
+				return err
+			}
+			p.outputFormat = format
+			return nil
+		}
+	default:
+		return p.BaseProvider.SetProperty(property, value)
+	}
+	return fmt.Errorf("invalid property or value type: %s", property)
 }
 
-type voiceSettings struct {
-	Stability       float64 `json:"stability"`
-	SimilarityBoost float64 `json:"similarity_boost"`
+// SetVoiceSettings replaces the stability, similarity, style and speaker
+// boost settings
+func (p *ElevenLabsProvider) SetVoiceSettings(settings ElevenLabsVoiceSettings) {
+	p.settings = settings
 }
 
-func (p *ElevenLabsProvider) synthesize(ctx context.Context, text string, isSSML bool) ([]byte, error) {
-	if isSSML {
-		return nil, fmt.Errorf("SSML not supported by ElevenLabs")
-	}
+type synthesisRequest struct {
+	Text          string                  `json:"text"`
+	ModelID       string                  `json:"model_id,omitempty"`
+	VoiceSettings ElevenLabsVoiceSettings `json:"voice_settings"`
+}
+
+// timestampsResponse is the body returned by the with-timestamps endpoint
+type timestampsResponse struct {
+	AudioBase64 string `json:"audio_base64"`
+	Alignment   *struct {
+		Characters []string  `json:"characters"`
+		Starts     []float64 `json:"character_start_times_seconds"`
+		Ends       []float64 `json:"character_end_times_seconds"`
+	} `json:"alignment"`
+}
 
+// request posts text to the text-to-speech endpoint with the given suffix
+// ("", "/stream" or "/with-timestamps") and returns the successful response
+func (p *ElevenLabsProvider) request(ctx context.Context, text, endpoint string) (*http.Response, error) {
 	reqBody := synthesisRequest{
-		Text: text,
-		VoiceSettings: voiceSettings{
-			Stability:       0.75,
-			SimilarityBoost: 0.75,
-		},
+		Text:          text,
+		ModelID:       p.modelID,
+		VoiceSettings: p.settings,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -63,13 +178,13 @@ func (p *ElevenLabsProvider) synthesize(ctx context.Context, text string, isSSML
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/text-to-speech/%s", elevenLabsBaseURL, p.config.VoiceID)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	endpointURL := fmt.Sprintf("%s/text-to-speech/%s%s?output_format=%s",
+		p.baseURL, url.PathEscape(p.config.VoiceID), endpoint, url.QueryEscape(p.outputFormat))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "audio/mpeg")
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("xi-api-key", p.apiKey)
 
@@ -77,63 +192,214 @@ func (p *ElevenLabsProvider) synthesize(ctx context.Context, text string, isSSML
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
 	}
+	return resp, nil
+}
+
+func (p *ElevenLabsProvider) synthesize(ctx context.Context, text string, isSSML bool) ([]byte, error) {
+	if isSSML {
+		return nil, fmt.Errorf("SSML not supported by ElevenLabs")
+	}
+
+	resp, err := p.request(ctx, text, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
 	return io.ReadAll(resp.Body)
 }
 
-func (p *ElevenLabsProvider) Speak(ctx context.Context, text string) error {
-	audioData, err := p.synthesize(ctx, text, false)
+// synthesizeWithTimestamps synthesizes text and returns its audio with word
+// timings built from the character alignment
+func (p *ElevenLabsProvider) synthesizeWithTimestamps(ctx context.Context, text string) ([]byte, []Timing, error) {
+	resp, err := p.request(ctx, text, "/with-timestamps")
 	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	var result timestampsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	audioData, err := base64.StdEncoding.DecodeString(result.AudioBase64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+
+	var timings []Timing
+	if a := result.Alignment; a != nil {
+		timings = alignmentWords(a.Characters, a.Starts, a.Ends)
+	}
+	return audioData, timings, nil
+}
+
+// alignmentWords joins per-character timings into word timings
+func alignmentWords(chars []string, starts, ends []float64) []Timing {
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second))
+	}
+
+	var timings []Timing
+	var word strings.Builder
+	var start, end float64
+	flush := func() {
+		if word.Len() > 0 {
+			timings = append(timings, Timing{Kind: WordTiming, Text: word.String(), Start: seconds(start), End: seconds(end)})
+			word.Reset()
+		}
+	}
+	for i, c := range chars {
+		if i >= len(starts) || i >= len(ends) {
+			break
+		}
+		if strings.TrimFunc(c, unicode.IsSpace) == "" {
+			flush()
+			continue
+		}
+		if word.Len() == 0 {
+			start = starts[i]
+		}
+		word.WriteString(c)
+		end = ends[i]
+	}
+	flush()
+	return timings
+}
+
+// wrapRaw returns audio in a headerless output format (PCM, mu-law or
+// A-law) as WAV, so it can be decoded and saved like the other formats
+func (p *ElevenLabsProvider) wrapRaw(audioData []byte) []byte {
+	codec, rate, _ := parseElevenLabsFormat(p.outputFormat)
+	encodings := map[string]audio.Encoding{"pcm": audio.PCM16, "ulaw": audio.MuLaw, "alaw": audio.ALaw}
+	enc, ok := encodings[codec]
+	if !ok {
+		return audioData
+	}
+	var wav bytes.Buffer
+	audio.WriteWAVHeader(&wav, rate, 1, enc, uint32(len(audioData)))
+	wav.Write(audioData)
+	return wav.Bytes()
+}
+
+// isRaw reports whether the output format has no header
+func (p *ElevenLabsProvider) isRaw() bool {
+	codec, _, _ := parseElevenLabsFormat(p.outputFormat)
+	return codec == "pcm" || codec == "ulaw" || codec == "alaw"
+}
+
+func (p *ElevenLabsProvider) Speak(ctx context.Context, text string) error {
+	text, _ = p.PrepareText(text, false)
+
+	// Word events need the with-timestamps endpoint, which returns the
+	// audio in one piece; otherwise playback starts as audio streams in
+	var body io.Reader
+	var timings []Timing
+	if p.Listening(EventWord) || p.isRaw() {
+		var audioData []byte
+		var err error
+		if p.Listening(EventWord) {
+			audioData, timings, err = p.synthesizeWithTimestamps(ctx, text)
+		} else {
+			audioData, err = p.synthesize(ctx, text, false)
+		}
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(p.wrapRaw(audioData))
+	} else {
+		resp, err := p.request(ctx, text, "/stream")
+		if err != nil {
+			return err
+		}
+		body = resp.Body
+	}
+
+	if err := p.PreparePlayback(p.audioPlayer); err != nil {
+		if c, ok := body.(io.Closer); ok {
+			c.Close()
+		}
+		return err
+	}
+	return p.PlayWithEvents(p.audioPlayer, body, timings)
+}
+
+// PreparePlayback applies the output settings to player. The service
+// ignores rate, pitch and volume, so they are applied during playback.
+func (p *ElevenLabsProvider) PreparePlayback(player *AudioPlayer) error {
+	if err := p.PrepareAudio(player); err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+	p.EmulateProsody(player)
+	return nil
 }
 
 func (p *ElevenLabsProvider) SpeakSSML(ctx context.Context, ssml string) error {
 	return fmt.Errorf("SSML not supported by ElevenLabs")
 }
 
-func (p *ElevenLabsProvider) GetVoices(ctx context.Context) ([]Voice, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", elevenLabsBaseURL+"/voices", nil)
+// SpeakStreamed writes the synthesized audio to w as it arrives from the
+// streaming endpoint, in the configured output format
+func (p *ElevenLabsProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, _ = p.PrepareText(text, false)
+	resp, err := p.request(ctx, text, "/stream")
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
 
-	req.Header.Set("xi-api-key", p.apiKey)
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
 
-	resp, err := p.client.Do(req)
+// SynthToFile synthesizes text and writes the audio to filename. Headerless
+// output formats are written as WAV.
+func (p *ElevenLabsProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, bytes.NewReader(p.wrapRaw(buf.Bytes())))
+}
+
+// SynthToFileWithTimings synthesizes text to filename and returns its word
+// timings from the character alignment
+func (p *ElevenLabsProvider) SynthToFileWithTimings(ctx context.Context, text, filename string) ([]Timing, error) {
+	text, _ = p.PrepareText(text, false)
+	audioData, timings, err := p.synthesizeWithTimestamps(ctx, text)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get voices: status %d", resp.StatusCode)
+	if err := p.WriteAudioFile(filename, bytes.NewReader(p.wrapRaw(audioData))); err != nil {
+		return nil, err
 	}
+	return timings, nil
+}
 
-	var result struct {
-		Voices []struct {
-			VoiceID  string `json:"voice_id"`
-			Name     string `json:"name"`
-			Category string `json:"category"`
-		} `json:"voices"`
-	}
+func (p *ElevenLabsProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	return fmt.Errorf("SSML not supported by ElevenLabs")
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+func (p *ElevenLabsProvider) GetVoices(ctx context.Context) ([]Voice, error) {
+	details, err := p.ListVoiceDetails(ctx)
+	if err != nil {
 		return nil, err
 	}
 
-	voices := make([]Voice, 0, len(result.Voices))
-	for _, v := range result.Voices {
+	voices := make([]Voice, 0, len(details))
+	for _, v := range details {
 		voices = append(voices, Voice{
-			ID:       v.VoiceID,
-			Name:     v.Name,
-			Provider: "ElevenLabs",
+			ID:          v.VoiceID,
+			Name:        v.Name,
+			Language:    v.Labels["language"],
+			Gender:      v.Labels["gender"],
+			Provider:    "ElevenLabs",
+			NativeVoice: v,
 		})
 	}
 
@@ -153,23 +419,22 @@ func (p *ElevenLabsProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
-func (p *ElevenLabsProvider) SetOutputDevice(deviceID string) error {
-	return ErrNotImplemented
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *ElevenLabsProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
 }
 
-func (p *ElevenLabsProvider) CheckCredentials(ctx context.Context) bool {
-	req, err := http.NewRequestWithContext(ctx, "GET", elevenLabsBaseURL+"/user/subscription", nil)
-	if err != nil {
-		return false
-	}
-
-	req.Header.Set("xi-api-key", p.apiKey)
+// SetOutputDevice routes playback to the output device with the given ID
+func (p *ElevenLabsProvider) SetOutputDevice(deviceID string) error {
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
 
-	resp, err := p.client.Do(req)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
+// Player returns the audio player, for live playback controls
+func (p *ElevenLabsProvider) Player() *AudioPlayer {
+	return p.audioPlayer
+}
 
-	return resp.StatusCode == http.StatusOK
+func (p *ElevenLabsProvider) CheckCredentials(ctx context.Context) bool {
+	_, err := p.GetSubscription(ctx)
+	return err == nil
 }
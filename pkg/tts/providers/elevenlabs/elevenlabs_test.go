@@ -0,0 +1,178 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestProvider returns a provider that talks to an httptest stand-in for
+// the ElevenLabs API
+func newTestProvider(t *testing.T, mux *http.ServeMux) *ElevenLabsProvider {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("xi-api-key") != "test-key" {
+			http.Error(w, `{"detail":"invalid api key"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return &ElevenLabsProvider{
+		BaseProvider: NewBaseProvider(TTSConfig{}),
+		apiKey:       "test-key",
+		client:       server.Client(),
+		baseURL:      server.URL,
+	}
+}
+
+func TestElevenLabsVoiceManagement(t *testing.T) {
+	ctx := context.Background()
+	mux := http.NewServeMux()
+	deleted := ""
+
+	mux.HandleFunc("GET /voices", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"voices":[{
+			"voice_id":"v1","name":"Rachel","category":"premade",
+			"labels":{"gender":"female","accent":"american"},
+			"preview_url":"https://example.com/rachel.mp3",
+			"fine_tuning":{"is_allowed_to_fine_tune":true,"state":{"eleven_multilingual_v2":"fine_tuned"}}
+		}]}`)
+	})
+	mux.HandleFunc("GET /voices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("with_settings") != "true" {
+			t.Error("voice settings were not requested")
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"voice_id": r.PathValue("id"),
+			"name":     "Rachel",
+			"settings": map[string]interface{}{"stability": 0.5, "similarity_boost": 0.8},
+		})
+	})
+	mux.HandleFunc("POST /voices/add", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed to parse upload: %v", err)
+			return
+		}
+		if got := r.FormValue("name"); got != "Clone" {
+			t.Errorf("name = %q, want Clone", got)
+		}
+		if got := r.FormValue("labels"); got != `{"accent":"british"}` {
+			t.Errorf("labels = %q", got)
+		}
+		files := r.MultipartForm.File["files"]
+		if len(files) != 2 || files[0].Filename != "one.mp3" {
+			t.Errorf("unexpected samples: %v", files)
+			return
+		}
+		f, _ := files[1].Open()
+		data, _ := io.ReadAll(f)
+		if string(data) != "second sample" {
+			t.Errorf("sample data = %q", data)
+		}
+		io.WriteString(w, `{"voice_id":"cloned"}`)
+	})
+	mux.HandleFunc("POST /voices/{id}/edit", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "cloned" || r.FormValue("description") != "Renamed" {
+			t.Errorf("unexpected edit of %s: %v", r.PathValue("id"), r.Form)
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("DELETE /voices/{id}", func(w http.ResponseWriter, r *http.Request) {
+		deleted = r.PathValue("id")
+		io.WriteString(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("GET /user/subscription", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"tier":"creator","character_count":1200,"character_limit":100000,"next_character_count_reset_unix":1767225600}`)
+	})
+
+	var provider ElevenLabsVoiceManager = newTestProvider(t, mux)
+
+	voices, err := provider.ListVoiceDetails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voices) != 1 {
+		t.Fatalf("got %d voices, want 1", len(voices))
+	}
+	v := voices[0]
+	if v.Labels["accent"] != "american" || v.PreviewURL == "" || !v.FineTuning.IsAllowed ||
+		v.FineTuning.State["eleven_multilingual_v2"] != "fine_tuned" {
+		t.Errorf("voice details not decoded: %+v", v)
+	}
+
+	detail, err := provider.GetVoiceDetails(ctx, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detail.Settings == nil || detail.Settings.SimilarityBoost != 0.8 {
+		t.Errorf("voice settings not decoded: %+v", detail.Settings)
+	}
+
+	id, err := provider.AddVoice(ctx, ElevenLabsVoiceUpload{
+		Name:   "Clone",
+		Labels: map[string]string{"accent": "british"},
+		Samples: []ElevenLabsSample{
+			{Filename: "one.mp3", Data: strings.NewReader("first sample")},
+			{Filename: "two.mp3", Data: strings.NewReader("second sample")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "cloned" {
+		t.Errorf("voice ID = %q, want cloned", id)
+	}
+	if _, err := provider.AddVoice(ctx, ElevenLabsVoiceUpload{Name: "Empty"}); err == nil {
+		t.Error("expected an error when cloning without samples")
+	}
+
+	if err := provider.EditVoice(ctx, id, ElevenLabsVoiceUpload{Name: "Clone", Description: "Renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.DeleteVoice(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if deleted != "cloned" {
+		t.Errorf("deleted %q, want cloned", deleted)
+	}
+
+	sub, err := provider.GetSubscription(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Tier != "creator" || sub.Remaining() != 98800 || sub.NextReset().Unix() != 1767225600 {
+		t.Errorf("unexpected subscription: %+v", sub)
+	}
+}
+
+func TestElevenLabsAPIErrors(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, http.NewServeMux())
+
+	if err := provider.DeleteVoice(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+
+	provider.apiKey = "wrong"
+	if provider.CheckCredentials(ctx) {
+		t.Error("CheckCredentials accepted a rejected key")
+	}
+	if _, err := provider.GetVoices(ctx); err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("expected the API error detail, got %v", err)
+	}
+}
+
+func TestElevenLabsOpusFormat(t *testing.T) {
+	_, _, err := parseElevenLabsFormat("opus_48000_64")
+	if OpusSupported() != (err == nil) {
+		t.Errorf("parseElevenLabsFormat(opus) error = %v with Opus support %v", err, OpusSupported())
+	}
+	if _, rate, err := parseElevenLabsFormat("pcm_16000"); err != nil || rate != 16000 {
+		t.Errorf("parseElevenLabsFormat(pcm_16000) = %d, %v", rate, err)
+	}
+}
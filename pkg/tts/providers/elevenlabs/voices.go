@@ -0,0 +1,217 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ElevenLabsVoiceManager is implemented by ElevenLabsProvider to manage the
+// voices of an account, including instant voice clones
+type ElevenLabsVoiceManager interface {
+	ListVoiceDetails(ctx context.Context) ([]ElevenLabsVoice, error)
+	GetVoiceDetails(ctx context.Context, voiceID string) (*ElevenLabsVoice, error)
+	AddVoice(ctx context.Context, voice ElevenLabsVoiceUpload) (string, error)
+	EditVoice(ctx context.Context, voiceID string, voice ElevenLabsVoiceUpload) error
+	DeleteVoice(ctx context.Context, voiceID string) error
+	GetSubscription(ctx context.Context) (*ElevenLabsSubscription, error)
+}
+
+// ElevenLabsVoice describes a voice available to the account
+type ElevenLabsVoice struct {
+	VoiceID     string                   `json:"voice_id"`
+	Name        string                   `json:"name"`
+	Category    string                   `json:"category"` // e.g. "premade", "cloned" or "generated"
+	Description string                   `json:"description"`
+	Labels      map[string]string        `json:"labels"` // e.g. accent, age, gender
+	PreviewURL  string                   `json:"preview_url"`
+	FineTuning  ElevenLabsFineTuning     `json:"fine_tuning"`
+	Settings    *ElevenLabsVoiceSettings `json:"settings"`
+}
+
+// ElevenLabsFineTuning reports whether a voice can be fine-tuned and its
+// fine-tuning state for each model
+type ElevenLabsFineTuning struct {
+	IsAllowed bool              `json:"is_allowed_to_fine_tune"`
+	State     map[string]string `json:"state"` // model ID to e.g. "not_started" or "fine_tuned"
+}
+
+// ElevenLabsVoiceUpload describes a voice to add or edit. Adding a voice
+// with samples creates an instant voice clone.
+type ElevenLabsVoiceUpload struct {
+	Name        string
+	Description string
+	Labels      map[string]string
+	Samples     []ElevenLabsSample
+}
+
+// ElevenLabsSample is a recording of the voice to clone
+type ElevenLabsSample struct {
+	Filename string // The extension tells the service the format, e.g. "sample.mp3"
+	Data     io.Reader
+}
+
+// ElevenLabsSubscription reports the account's character usage
+type ElevenLabsSubscription struct {
+	Tier           string `json:"tier"`
+	CharacterCount int    `json:"character_count"`
+	CharacterLimit int    `json:"character_limit"`
+	NextResetUnix  int64  `json:"next_character_count_reset_unix"`
+}
+
+// NextReset returns when the character count is next reset
+func (s *ElevenLabsSubscription) NextReset() time.Time {
+	return time.Unix(s.NextResetUnix, 0)
+}
+
+// Remaining returns the number of characters left in the current period
+func (s *ElevenLabsSubscription) Remaining() int {
+	return max(s.CharacterLimit-s.CharacterCount, 0)
+}
+
+// do sends an API request and decodes a JSON response into out, if not nil
+func (p *ElevenLabsProvider) do(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("xi-api-key", p.apiKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// ListVoiceDetails returns every voice available to the account
+func (p *ElevenLabsProvider) ListVoiceDetails(ctx context.Context) ([]ElevenLabsVoice, error) {
+	var result struct {
+		Voices []ElevenLabsVoice `json:"voices"`
+	}
+	if err := p.do(ctx, "GET", "/voices", "", nil, &result); err != nil {
+		return nil, fmt.Errorf("failed to get voices: %w", err)
+	}
+	return result.Voices, nil
+}
+
+// GetVoiceDetails returns one voice, including its settings
+func (p *ElevenLabsProvider) GetVoiceDetails(ctx context.Context, voiceID string) (*ElevenLabsVoice, error) {
+	var voice ElevenLabsVoice
+	path := "/voices/" + url.PathEscape(voiceID) + "?with_settings=true"
+	if err := p.do(ctx, "GET", path, "", nil, &voice); err != nil {
+		return nil, fmt.Errorf("failed to get voice %s: %w", voiceID, err)
+	}
+	return &voice, nil
+}
+
+// AddVoice creates an instant voice clone from the uploaded samples and
+// returns its voice ID
+func (p *ElevenLabsProvider) AddVoice(ctx context.Context, voice ElevenLabsVoiceUpload) (string, error) {
+	if voice.Name == "" {
+		return "", fmt.Errorf("a voice needs a name")
+	}
+	if len(voice.Samples) == 0 {
+		return "", fmt.Errorf("voice cloning needs at least one sample")
+	}
+
+	body, contentType, err := voiceForm(voice)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		VoiceID string `json:"voice_id"`
+	}
+	if err := p.do(ctx, "POST", "/voices/add", contentType, body, &result); err != nil {
+		return "", fmt.Errorf("failed to add voice: %w", err)
+	}
+	return result.VoiceID, nil
+}
+
+// EditVoice replaces the name, description and labels of a voice and adds
+// any samples given
+func (p *ElevenLabsProvider) EditVoice(ctx context.Context, voiceID string, voice ElevenLabsVoiceUpload) error {
+	if voice.Name == "" {
+		return fmt.Errorf("a voice needs a name")
+	}
+
+	body, contentType, err := voiceForm(voice)
+	if err != nil {
+		return err
+	}
+	path := "/voices/" + url.PathEscape(voiceID) + "/edit"
+	if err := p.do(ctx, "POST", path, contentType, body, nil); err != nil {
+		return fmt.Errorf("failed to edit voice %s: %w", voiceID, err)
+	}
+	return nil
+}
+
+// DeleteVoice removes a voice from the account
+func (p *ElevenLabsProvider) DeleteVoice(ctx context.Context, voiceID string) error {
+	if err := p.do(ctx, "DELETE", "/voices/"+url.PathEscape(voiceID), "", nil, nil); err != nil {
+		return fmt.Errorf("failed to delete voice %s: %w", voiceID, err)
+	}
+	return nil
+}
+
+// GetSubscription returns the account's tier and character usage
+func (p *ElevenLabsProvider) GetSubscription(ctx context.Context) (*ElevenLabsSubscription, error) {
+	var sub ElevenLabsSubscription
+	if err := p.do(ctx, "GET", "/user/subscription", "", nil, &sub); err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+// voiceForm encodes a voice upload as the multipart form the voice
+// endpoints expect
+func voiceForm(voice ElevenLabsVoiceUpload) (io.Reader, string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	form.WriteField("name", voice.Name)
+	if voice.Description != "" {
+		form.WriteField("description", voice.Description)
+	}
+	if len(voice.Labels) > 0 {
+		labels, err := json.Marshal(voice.Labels)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode labels: %w", err)
+		}
+		form.WriteField("labels", string(labels))
+	}
+	for _, sample := range voice.Samples {
+		part, err := form.CreateFormFile("files", sample.Filename)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := io.Copy(part, sample.Data); err != nil {
+			return nil, "", fmt.Errorf("failed to read sample %s: %w", sample.Filename, err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, form.FormDataContentType(), nil
+}
@@ -1,20 +1,35 @@
 package tts
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	texttospeech "cloud.google.com/go/texttospeech/apiv1"
 	"cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	texttospeechbeta "google.golang.org/api/texttospeech/v1beta1"
 )
 
 // GoogleProvider implements TTSProvider for Google Cloud TTS
 type GoogleProvider struct {
 	*BaseProvider
-	client      *texttospeech.Client
-	audioPlayer *AudioPlayer
+	client          *texttospeech.Client
+	beta            *texttospeechbeta.Service // REST v1beta1 API, the only one that reports mark timepoints
+	audioPlayer     *AudioPlayer
+	effectsProfiles []string // audio effects profiles, e.g. "headphone-class-device"
+	customVoice     string   // resource name of a custom voice model
 }
 
+// googleWordMark prefixes the marks inserted before each word of plain text
+// so that word timings can be read from the timepoints
+const googleWordMark = "tts-word-"
+
 // NewGoogleProvider creates a new Google Cloud TTS provider
 func NewGoogleProvider(cfg TTSConfig) (*GoogleProvider, error) {
 	ctx := context.Background()
@@ -23,6 +38,12 @@ func NewGoogleProvider(cfg TTSConfig) (*GoogleProvider, error) {
 		return nil, err
 	}
 
+	beta, err := texttospeechbeta.NewService(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
 	audioPlayer, err := NewAudioPlayer()
 	if err != nil {
 		client.Close()
@@ -32,10 +53,36 @@ func NewGoogleProvider(cfg TTSConfig) (*GoogleProvider, error) {
 	return &GoogleProvider{
 		BaseProvider: NewBaseProvider(cfg),
 		client:      client,
+		beta:        beta,
 		audioPlayer: audioPlayer,
 	}, nil
 }
 
+// SetProperty handles the Google-specific "effects_profile" (string or
+// []string) and "custom_voice" (string, a model resource name) properties
+// and passes the rest to BaseProvider
+func (p *GoogleProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "effects_profile":
+		switch profiles := value.(type) {
+		case string:
+			p.effectsProfiles = []string{profiles}
+			return nil
+		case []string:
+			p.effectsProfiles = append([]string(nil), profiles...)
+			return nil
+		}
+		return fmt.Errorf("invalid property or value type: %s", property)
+	case "custom_voice":
+		if model, ok := value.(string); ok {
+			p.customVoice = model
+			return nil
+		}
+		return fmt.Errorf("invalid property or value type: %s", property)
+	}
+	return p.BaseProvider.SetProperty(property, value)
+}
+
 func (p *GoogleProvider) synthesize(ctx context.Context, text string, isSSML bool) (*texttospeechpb.SynthesizeSpeechResponse, error) {
 	req := &texttospeechpb.SynthesizeSpeechRequest{
 		Voice: &texttospeechpb.VoiceSelectionParams{
@@ -43,12 +90,17 @@ func (p *GoogleProvider) synthesize(ctx context.Context, text string, isSSML boo
 			Name:         p.config.VoiceID,
 		},
 		AudioConfig: &texttospeechpb.AudioConfig{
-			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-			SpeakingRate: p.audioConfig.Rate,
-			Pitch:        p.audioConfig.Pitch,
-			VolumeGainDb: 20 * p.audioConfig.Volume, // Convert to dB scale
+			AudioEncoding:    texttospeechpb.AudioEncoding_MP3,
+			SpeakingRate:     p.audioConfig.Rate,
+			Pitch:            p.audioConfig.Pitch,
+			VolumeGainDb:     20 * p.audioConfig.Volume, // Convert to dB scale
+			SampleRateHertz:  int32(p.audioConfig.SampleRate),
+			EffectsProfileId: p.effectsProfiles,
 		},
 	}
+	if p.customVoice != "" {
+		req.Voice.CustomVoice = &texttospeechpb.CustomVoiceParams{Model: p.customVoice}
+	}
 
 	if isSSML {
 		req.Input = &texttospeechpb.SynthesisInput{
@@ -63,23 +115,185 @@ func (p *GoogleProvider) synthesize(ctx context.Context, text string, isSSML boo
 	return p.client.SynthesizeSpeech(ctx, req)
 }
 
+// synthesizeWithMarks synthesizes SSML through the v1beta1 API and returns
+// the audio with the time of each SSML mark
+func (p *GoogleProvider) synthesizeWithMarks(ctx context.Context, ssml string) ([]byte, []*texttospeechbeta.Timepoint, error) {
+	req := &texttospeechbeta.SynthesizeSpeechRequest{
+		Input: &texttospeechbeta.SynthesisInput{Ssml: ssml},
+		Voice: &texttospeechbeta.VoiceSelectionParams{
+			LanguageCode: p.config.LanguageCode,
+			Name:         p.config.VoiceID,
+		},
+		AudioConfig: &texttospeechbeta.AudioConfig{
+			AudioEncoding:    "MP3",
+			SpeakingRate:     p.audioConfig.Rate,
+			Pitch:            p.audioConfig.Pitch,
+			VolumeGainDb:     20 * p.audioConfig.Volume, // Convert to dB scale
+			SampleRateHertz:  int64(p.audioConfig.SampleRate),
+			EffectsProfileId: p.effectsProfiles,
+		},
+		EnableTimePointing: []string{"SSML_MARK"},
+	}
+	if p.customVoice != "" {
+		req.Voice.CustomVoice = &texttospeechbeta.CustomVoiceParams{Model: p.customVoice}
+	}
+
+	resp, err := p.beta.Text.Synthesize(req).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, err
+	}
+	audio, err := base64.StdEncoding.DecodeString(resp.AudioContent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode audio content: %w", err)
+	}
+	return audio, resp.Timepoints, nil
+}
+
 func (p *GoogleProvider) Speak(ctx context.Context, text string) error {
-	resp, err := p.synthesize(ctx, text, false)
+	text, isSSML := p.PrepareText(text, true)
+	return p.play(ctx, text, isSSML)
+}
+
+func (p *GoogleProvider) SpeakSSML(ctx context.Context, ssml string) error {
+	if err := p.ValidateSSML(ssml); err != nil {
+		return err
+	}
+	return p.play(ctx, p.PrepareSSML(ssml), true)
+}
+
+// play synthesizes and plays text, asking for the timepoints connected
+// callbacks need and firing them as playback reaches them
+func (p *GoogleProvider) play(ctx context.Context, text string, isSSML bool) error {
+	var words []string
+	if !isSSML && p.Listening(EventWord) {
+		words = strings.Fields(text)
+		text, isSSML = markWords(words), true
+	}
+	if len(words) == 0 && !(isSSML && p.Listening(EventMark)) {
+		resp, err := p.synthesize(ctx, text, isSSML)
+		if err != nil {
+			return err
+		}
+		if err := p.PrepareAudio(p.audioPlayer); err != nil {
+			return err
+		}
+		return p.audioPlayer.Play(bytes.NewReader(resp.AudioContent))
+	}
+
+	audio, timepoints, err := p.synthesizeWithMarks(ctx, text)
+	if err != nil {
+		return err
+	}
+	if err := p.PrepareAudio(p.audioPlayer); err != nil {
+		return err
+	}
+	timings := googleTimings(timepoints, words)
+	FillTimingEnds(timings, 0)
+	return p.PlayWithEvents(p.audioPlayer, io.NopCloser(bytes.NewReader(audio)), timings)
+}
+
+// markWords turns plain text into SSML with a mark before each word
+func markWords(words []string) string {
+	var ssml strings.Builder
+	ssml.WriteString("<speak>")
+	for i, word := range words {
+		if i > 0 {
+			ssml.WriteByte(' ')
+		}
+		fmt.Fprintf(&ssml, `<mark name="%s%d"/>%s`, googleWordMark, i, escapeXML(word))
+	}
+	ssml.WriteString("</speak>")
+	return ssml.String()
+}
+
+// googleTimings converts timepoints to timings. Marks inserted by markWords
+// become word timings; other marks are SSML mark timings.
+func googleTimings(timepoints []*texttospeechbeta.Timepoint, words []string) []Timing {
+	timings := make([]Timing, 0, len(timepoints))
+	for _, tp := range timepoints {
+		timing := Timing{
+			Kind:  MarkTiming,
+			Text:  tp.MarkName,
+			Start: time.Duration(math.Round(tp.TimeSeconds * float64(time.Second))),
+		}
+		if index, ok := strings.CutPrefix(tp.MarkName, googleWordMark); ok {
+			if i, err := strconv.Atoi(index); err == nil && i >= 0 && i < len(words) {
+				timing.Kind, timing.Text = WordTiming, words[i]
+			}
+		}
+		timings = append(timings, timing)
+	}
+	return timings
+}
+
+// SpeakStreamed writes the synthesized audio to w instead of playing it
+func (p *GoogleProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, isSSML := p.PrepareText(text, true)
+	resp, err := p.synthesize(ctx, text, isSSML)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(io.NopCloser(io.NewSectionReader(resp.AudioContent, 0, int64(len(resp.AudioContent)))))
+	_, err = w.Write(resp.AudioContent)
+	return err
 }
 
-func (p *GoogleProvider) SpeakSSML(ctx context.Context, ssml string) error {
+// SpeakSSMLStreamed writes the synthesized SSML audio to w instead of playing it
+func (p *GoogleProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
 	if err := p.ValidateSSML(ssml); err != nil {
 		return err
 	}
-	resp, err := p.synthesize(ctx, ssml, true)
+	resp, err := p.synthesize(ctx, p.PrepareSSML(ssml), true)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(io.NopCloser(io.NewSectionReader(resp.AudioContent, 0, int64(len(resp.AudioContent)))))
+	_, err = w.Write(resp.AudioContent)
+	return err
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *GoogleProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthSSMLToFile synthesizes SSML and writes the audio to filename
+func (p *GoogleProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakSSMLStreamed(ctx, ssml, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthToFileWithTimings synthesizes text to filename and returns its word
+// timings, measured with a mark before each word. Text that becomes SSML
+// through a lexicon has no word timings.
+func (p *GoogleProvider) SynthToFileWithTimings(ctx context.Context, text, filename string) ([]Timing, error) {
+	text, isSSML := p.PrepareText(text, true)
+	var words []string
+	if !isSSML {
+		words = strings.Fields(text)
+		text = markWords(words)
+	}
+
+	audio, timepoints, err := p.synthesizeWithMarks(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	total, err := AudioDuration(bytes.NewReader(audio))
+	if err != nil {
+		return nil, err
+	}
+	timings := googleTimings(timepoints, words)
+	FillTimingEnds(timings, total)
+
+	if err := p.WriteAudioFile(filename, bytes.NewReader(audio)); err != nil {
+		return nil, err
+	}
+	return timings, nil
 }
 
 func (p *GoogleProvider) GetVoices(ctx context.Context) ([]Voice, error) {
@@ -118,9 +332,19 @@ func (p *GoogleProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *GoogleProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
 func (p *GoogleProvider) SetOutputDevice(deviceID string) error {
-	// Not implemented for Google Cloud TTS
-	return ErrNotImplemented
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *GoogleProvider) Player() *AudioPlayer {
+	return p.audioPlayer
 }
 
 func (p *GoogleProvider) CheckCredentials(ctx context.Context) bool {
@@ -0,0 +1,88 @@
+package tts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	texttospeechbeta "google.golang.org/api/texttospeech/v1beta1"
+)
+
+func TestMarkWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  string
+	}{
+		{"empty", nil, "<speak></speak>"},
+		{"one word", []string{"Hello"}, `<speak><mark name="tts-word-0"/>Hello</speak>`},
+		{"escaped", []string{"Fish", "&", "<chips>"},
+			`<speak><mark name="tts-word-0"/>Fish <mark name="tts-word-1"/>&amp; <mark name="tts-word-2"/>&lt;chips&gt;</speak>`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := markWords(tc.words); got != tc.want {
+				t.Errorf("markWords(%q) = %q, want %q", tc.words, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGoogleTimings(t *testing.T) {
+	words := []string{"Hello", "world"}
+	tests := []struct {
+		name       string
+		timepoints []*texttospeechbeta.Timepoint
+		want       []Timing
+	}{
+		{
+			name: "word marks",
+			timepoints: []*texttospeechbeta.Timepoint{
+				{MarkName: "tts-word-0", TimeSeconds: 0.1},
+				{MarkName: "tts-word-1", TimeSeconds: 0.55},
+			},
+			want: []Timing{
+				{Kind: WordTiming, Text: "Hello", Start: 100 * time.Millisecond},
+				{Kind: WordTiming, Text: "world", Start: 550 * time.Millisecond},
+			},
+		},
+		{
+			name: "out of range indices",
+			timepoints: []*texttospeechbeta.Timepoint{
+				{MarkName: "tts-word-2", TimeSeconds: 0.2},
+				{MarkName: "tts-word--1", TimeSeconds: 0.3},
+				{MarkName: "tts-word-x", TimeSeconds: 0.4},
+			},
+			want: []Timing{
+				{Kind: MarkTiming, Text: "tts-word-2", Start: 200 * time.Millisecond},
+				{Kind: MarkTiming, Text: "tts-word--1", Start: 300 * time.Millisecond},
+				{Kind: MarkTiming, Text: "tts-word-x", Start: 400 * time.Millisecond},
+			},
+		},
+		{
+			name: "mixed user marks",
+			timepoints: []*texttospeechbeta.Timepoint{
+				{MarkName: "tts-word-0", TimeSeconds: 0.1},
+				{MarkName: "chapter", TimeSeconds: 0.5},
+				{MarkName: "tts-word-1", TimeSeconds: 0.6},
+			},
+			want: []Timing{
+				{Kind: WordTiming, Text: "Hello", Start: 100 * time.Millisecond},
+				{Kind: MarkTiming, Text: "chapter", Start: 500 * time.Millisecond},
+				{Kind: WordTiming, Text: "world", Start: 600 * time.Millisecond},
+			},
+		},
+		{
+			name:       "no timepoints",
+			timepoints: nil,
+			want:       []Timing{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := googleTimings(tc.timepoints, words); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("googleTimings() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
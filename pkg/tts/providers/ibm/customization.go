@@ -0,0 +1,95 @@
+package tts
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/watson-developer-cloud/go-sdk/v3/texttospeechv1"
+)
+
+// WatsonCustomizer is implemented by the Watson providers to manage custom
+// pronunciation models
+type WatsonCustomizer interface {
+	// CreateCustomModel creates an empty custom model and returns its
+	// customization ID
+	CreateCustomModel(ctx context.Context, name, language, description string) (string, error)
+	// AddWords adds or replaces words in a custom model. Each word maps to a
+	// sounds-like spelling or an SSML phoneme element.
+	AddWords(ctx context.Context, customizationID string, words map[string]string) error
+	// SetCustomizationID applies a custom model to later synthesis; an
+	// empty ID removes it
+	SetCustomizationID(customizationID string)
+}
+
+// watsonServiceURL returns the service URL for a region, which is either an
+// IBM Cloud region ID such as "eu-gb" or the full URL of a service instance.
+// An empty region uses us-south.
+func watsonServiceURL(region string) string {
+	switch {
+	case region == "":
+		return texttospeechv1.DefaultServiceURL
+	case strings.HasPrefix(region, "https://"), strings.HasPrefix(region, "http://"):
+		return strings.TrimSuffix(region, "/")
+	default:
+		return fmt.Sprintf("https://api.%s.text-to-speech.watson.cloud.ibm.com", region)
+	}
+}
+
+// newSynthesizeOptions builds a synthesis request. The service detects SSML
+// itself, so plain text and SSML share the same options.
+func newSynthesizeOptions(client *texttospeechv1.TextToSpeechV1, text, voice, customizationID string) *texttospeechv1.SynthesizeOptions {
+	options := client.NewSynthesizeOptions(text).
+		SetAccept("audio/mp3").
+		SetVoice(voice)
+	if customizationID != "" {
+		options.SetCustomizationID(customizationID)
+	}
+	return options
+}
+
+// createCustomModel creates a custom model and returns its customization ID
+func createCustomModel(ctx context.Context, client *texttospeechv1.TextToSpeechV1, name, language, description string) (string, error) {
+	options := client.NewCreateCustomModelOptions(name)
+	if language != "" {
+		options.SetLanguage(language)
+	}
+	if description != "" {
+		options.SetDescription(description)
+	}
+
+	model, _, err := client.CreateCustomModelWithContext(ctx, options)
+	if err != nil {
+		return "", fmt.Errorf("failed to create custom voice model: %w", err)
+	}
+	return *model.CustomizationID, nil
+}
+
+// addWords adds words and their translations to a custom model
+func addWords(ctx context.Context, client *texttospeechv1.TextToSpeechV1, customizationID string, words map[string]string) error {
+	if customizationID == "" {
+		return fmt.Errorf("no custom model to add words to")
+	}
+
+	// Send the words in a stable order
+	graphemes := make([]string, 0, len(words))
+	for word := range words {
+		graphemes = append(graphemes, word)
+	}
+	sort.Strings(graphemes)
+
+	entries := make([]texttospeechv1.Word, 0, len(words))
+	for _, word := range graphemes {
+		entries = append(entries, texttospeechv1.Word{
+			Word:        core.StringPtr(word),
+			Translation: core.StringPtr(words[word]),
+		})
+	}
+
+	if _, err := client.AddWordsWithContext(ctx, client.NewAddWordsOptions(customizationID, entries)); err != nil {
+		return fmt.Errorf("failed to add words to custom model: %w", err)
+	}
+	return nil
+}
@@ -1,19 +1,22 @@
 package tts
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 
 	"github.com/IBM/go-sdk-core/v5/core"
-	"github.com/watson-developer-cloud/go-sdk/texttospeechv1"
+	"github.com/watson-developer-cloud/go-sdk/v3/texttospeechv1"
 	"github.com/willwade/go-tts-wrapper/pkg/tts"
 )
 
 // IBMProvider implements TTSProvider for IBM Watson
 type IBMProvider struct {
 	*tts.BaseProvider
-	client      *texttospeechv1.TextToSpeechV1
-	audioPlayer *tts.AudioPlayer
+	client          *texttospeechv1.TextToSpeechV1
+	audioPlayer     *tts.AudioPlayer
+	customizationID string // Custom pronunciation model applied to synthesis
 }
 
 // NewIBMProvider creates a new IBM Watson TTS provider
@@ -24,7 +27,7 @@ func NewIBMProvider(cfg tts.TTSConfig) (*IBMProvider, error) {
 
 	service, err := texttospeechv1.NewTextToSpeechV1(&texttospeechv1.TextToSpeechV1Options{
 		Authenticator: authenticator,
-		URL:           "https://api.us-south.text-to-speech.watson.cloud.ibm.com", // Default region
+		URL:           watsonServiceURL(cfg.Region),
 	})
 	if err != nil {
 		return nil, err
@@ -42,21 +45,45 @@ func NewIBMProvider(cfg tts.TTSConfig) (*IBMProvider, error) {
 	}, nil
 }
 
-func (p *IBMProvider) synthesize(ctx context.Context, text string, isSSML bool) (io.ReadCloser, error) {
-	var synthesizeOptions *texttospeechv1.SynthesizeOptions
+// SetProperty handles the "customization_id" property; others are passed
+// to BaseProvider
+func (p *IBMProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "customization_id":
+		if id, ok := value.(string); ok {
+			p.SetCustomizationID(id)
+			return nil
+		}
+	default:
+		return p.BaseProvider.SetProperty(property, value)
+	}
+	return fmt.Errorf("invalid property or value type: %s", property)
+}
+
+// SetCustomizationID applies a custom model to later synthesis; an empty
+// ID removes it
+func (p *IBMProvider) SetCustomizationID(customizationID string) {
+	p.customizationID = customizationID
+}
 
-	if isSSML {
-		synthesizeOptions = service.NewSynthesizeOptions(text).
-			SetAccept("audio/mp3").
-			SetVoice(p.config.VoiceID).
-			SetTextType("ssml")
-	} else {
-		synthesizeOptions = service.NewSynthesizeOptions(text).
-			SetAccept("audio/mp3").
-			SetVoice(p.config.VoiceID)
+// CreateCustomModel creates an empty custom pronunciation model and returns
+// its customization ID. Language defaults to the configured language.
+func (p *IBMProvider) CreateCustomModel(ctx context.Context, name, language, description string) (string, error) {
+	if language == "" {
+		language = p.config.LanguageCode
 	}
+	return createCustomModel(ctx, p.client, name, language, description)
+}
+
+// AddWords adds words to a custom model. Each word maps to a sounds-like
+// spelling or an SSML phoneme element.
+func (p *IBMProvider) AddWords(ctx context.Context, customizationID string, words map[string]string) error {
+	return addWords(ctx, p.client, customizationID, words)
+}
 
-	result, _, err := p.client.Synthesize(synthesizeOptions)
+func (p *IBMProvider) synthesize(ctx context.Context, text string) (io.ReadCloser, error) {
+	options := newSynthesizeOptions(p.client, text, p.config.VoiceID, p.customizationID)
+	result, _, err := p.client.SynthesizeWithContext(ctx, options)
 	if err != nil {
 		return nil, err
 	}
@@ -65,30 +92,115 @@ func (p *IBMProvider) synthesize(ctx context.Context, text string, isSSML bool)
 }
 
 func (p *IBMProvider) Speak(ctx context.Context, text string) error {
-	audio, err := p.synthesize(ctx, text, false)
+	text, _ = p.PrepareText(text, true)
+	return p.play(ctx, text)
+}
+
+func (p *IBMProvider) SpeakSSML(ctx context.Context, ssml string) error {
+	if err := p.ValidateSSML(ssml); err != nil {
+		return err
+	}
+	return p.play(ctx, p.PrepareSSML(ssml))
+}
+
+// play synthesizes and plays text. When word or mark callbacks are
+// connected, the WebSocket interface is used so they fire during playback.
+func (p *IBMProvider) play(ctx context.Context, text string) error {
+	if !p.Listening(tts.EventWord) && !p.Listening(tts.EventMark) {
+		audio, err := p.synthesize(ctx, text)
+		if err != nil {
+			return err
+		}
+
+		// The player closes the response body once it has been decoded
+		if err := p.PreparePlayback(p.audioPlayer); err != nil {
+			audio.Close()
+			return err
+		}
+		return p.audioPlayer.Play(audio)
+	}
+
+	audioData, timings, err := synthesizeWithTimings(ctx, p.client, text, p.config.VoiceID, p.customizationID)
+	if err != nil {
+		return err
+	}
+	if err := p.PreparePlayback(p.audioPlayer); err != nil {
+		return err
+	}
+	return p.PlayWithEvents(p.audioPlayer, io.NopCloser(bytes.NewReader(audioData)), timings)
+}
+
+// PreparePlayback applies the output settings to player. The service
+// ignores rate, pitch and volume, so they are applied during playback.
+func (p *IBMProvider) PreparePlayback(player *tts.AudioPlayer) error {
+	if err := p.PrepareAudio(player); err != nil {
+		return err
+	}
+	p.EmulateProsody(player)
+	return nil
+}
+
+func (p *IBMProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, _ = p.PrepareText(text, true)
+	audio, err := p.synthesize(ctx, text)
 	if err != nil {
 		return err
 	}
 	defer audio.Close()
 
-	return p.audioPlayer.PlayMP3Stream(audio)
+	_, err = io.Copy(w, audio)
+	return err
 }
 
-func (p *IBMProvider) SpeakSSML(ctx context.Context, ssml string) error {
+// SpeakSSMLStreamed writes the synthesized SSML audio to w instead of playing it
+func (p *IBMProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
 	if err := p.ValidateSSML(ssml); err != nil {
 		return err
 	}
-	audio, err := p.synthesize(ctx, ssml, true)
+	audio, err := p.synthesize(ctx, p.PrepareSSML(ssml))
 	if err != nil {
 		return err
 	}
 	defer audio.Close()
 
-	return p.audioPlayer.PlayMP3Stream(audio)
+	_, err = io.Copy(w, audio)
+	return err
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *IBMProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthSSMLToFile synthesizes SSML and writes the audio to filename
+func (p *IBMProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakSSMLStreamed(ctx, ssml, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthToFileWithTimings synthesizes text to filename and returns the word
+// timings and marks reported over the WebSocket interface
+func (p *IBMProvider) SynthToFileWithTimings(ctx context.Context, text, filename string) ([]tts.Timing, error) {
+	text, _ = p.PrepareText(text, true)
+	audioData, timings, err := synthesizeWithTimings(ctx, p.client, text, p.config.VoiceID, p.customizationID)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteAudioFile(filename, bytes.NewReader(audioData)); err != nil {
+		return nil, err
+	}
+	return timings, nil
 }
 
 func (p *IBMProvider) GetVoices(ctx context.Context) ([]tts.Voice, error) {
-	result, _, err := p.client.ListVoices(service.NewListVoicesOptions())
+	result, _, err := p.client.ListVoicesWithContext(ctx, p.client.NewListVoicesOptions())
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +232,22 @@ func (p *IBMProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *IBMProvider) SetAudioSink(sink tts.AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
+func (p *IBMProvider) SetOutputDevice(deviceID string) error {
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *IBMProvider) Player() *tts.AudioPlayer {
+	return p.audioPlayer
+}
+
 func (p *IBMProvider) CheckCredentials(ctx context.Context) bool {
-	_, _, err := p.client.ListVoices(service.NewListVoicesOptions())
+	_, _, err := p.client.ListVoicesWithContext(ctx, p.client.NewListVoicesOptions())
 	return err == nil
 }
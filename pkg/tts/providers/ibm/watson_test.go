@@ -0,0 +1,46 @@
+package tts
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/watson-developer-cloud/go-sdk/v3/texttospeechv1"
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func TestWatsonServiceURL(t *testing.T) {
+	tests := map[string]string{
+		"":      "https://api.us-south.text-to-speech.watson.cloud.ibm.com",
+		"eu-gb": "https://api.eu-gb.text-to-speech.watson.cloud.ibm.com",
+		"https://api.private.jp-tok.text-to-speech.watson.cloud.ibm.com/instances/abc/": "https://api.private.jp-tok.text-to-speech.watson.cloud.ibm.com/instances/abc",
+	}
+	for region, want := range tests {
+		if got := watsonServiceURL(region); got != want {
+			t.Errorf("watsonServiceURL(%q) = %q, want %q", region, got, want)
+		}
+	}
+}
+
+func TestWatsonCollector(t *testing.T) {
+	c := &watsonCollector{}
+	c.OnAudioStream([]byte("ab"))
+	c.OnTimingInformation(texttospeechv1.Timings{Words: [][]interface{}{
+		{"Hello", 0.1, 0.45},
+		{"world", 0.5, 0.9},
+	}})
+	c.OnMarks(texttospeechv1.Marks{Marks: [][]interface{}{{"here", 0.48}}})
+	c.OnAudioStream([]byte("cd"))
+
+	if got := c.audio.String(); got != "abcd" {
+		t.Errorf("audio = %q, want abcd", got)
+	}
+	want := []tts.Timing{
+		{Kind: tts.WordTiming, Text: "Hello", Start: 100 * time.Millisecond, End: 450 * time.Millisecond},
+		{Kind: tts.WordTiming, Text: "world", Start: 500 * time.Millisecond, End: 900 * time.Millisecond},
+		{Kind: tts.MarkTiming, Text: "here", Start: 480 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(c.timings, want) {
+		t.Errorf("timings = %v, want %v", c.timings, want)
+	}
+}
@@ -0,0 +1,115 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/watson-developer-cloud/go-sdk/v3/texttospeechv1"
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+// watsonCollector gathers the audio, word timings and marks sent over a
+// WebSocket synthesis connection
+type watsonCollector struct {
+	mu      sync.Mutex
+	audio   bytes.Buffer
+	timings []tts.Timing
+	err     error
+}
+
+func (c *watsonCollector) OnOpen()                       {}
+func (c *watsonCollector) OnClose()                      {}
+func (c *watsonCollector) OnContentType(string)          {}
+func (c *watsonCollector) OnData(*core.DetailedResponse) {}
+
+func (c *watsonCollector) OnError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *watsonCollector) OnAudioStream(data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.audio.Write(data)
+}
+
+// OnTimingInformation receives words as [word, start, end], in seconds
+func (c *watsonCollector) OnTimingInformation(timings texttospeechv1.Timings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range timings.Words {
+		if len(w) < 3 {
+			continue
+		}
+		text, _ := w[0].(string)
+		start, _ := w[1].(float64)
+		end, _ := w[2].(float64)
+		c.timings = append(c.timings, tts.Timing{
+			Kind:  tts.WordTiming,
+			Text:  text,
+			Start: watsonSeconds(start),
+			End:   watsonSeconds(end),
+		})
+	}
+}
+
+// OnMarks receives the SSML <mark> elements reached as [name, time]
+func (c *watsonCollector) OnMarks(marks texttospeechv1.Marks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range marks.Marks {
+		if len(m) < 2 {
+			continue
+		}
+		name, _ := m[0].(string)
+		at, _ := m[1].(float64)
+		c.timings = append(c.timings, tts.Timing{Kind: tts.MarkTiming, Text: name, Start: watsonSeconds(at)})
+	}
+}
+
+// watsonSeconds converts a time in seconds to a duration
+func watsonSeconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// synthesizeWithTimings synthesizes over the WebSocket interface, which
+// unlike the HTTP interface reports word timings and marks. Marks have no
+// end time.
+func synthesizeWithTimings(ctx context.Context, client *texttospeechv1.TextToSpeechV1, text, voice, customizationID string) ([]byte, []tts.Timing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	collector := &watsonCollector{}
+	options := client.NewSynthesizeUsingWebsocketOptions(text, collector)
+	options.SetAccept("audio/mp3")
+	options.SetVoice(voice)
+	if customizationID != "" {
+		options.SetCustomizationID(customizationID)
+	}
+	options.SetTimings([]string{"words"})
+
+	// Returns once the service closes the connection
+	if err := client.SynthesizeUsingWebsocket(options); err != nil {
+		return nil, nil, err
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.err != nil {
+		return nil, nil, fmt.Errorf("WebSocket synthesis failed: %w", collector.err)
+	}
+	sort.SliceStable(collector.timings, func(i, j int) bool {
+		return collector.timings[i].Start < collector.timings[j].Start
+	})
+	return collector.audio.Bytes(), collector.timings, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -58,23 +59,73 @@ func (p *ESpeakProvider) synthesize(ctx context.Context, text string, isSSML boo
 	return stdout.Bytes(), nil
 }
 
+// eSpeak ignores <phoneme>, so lexicon entries are applied as aliases
 func (p *ESpeakProvider) Speak(ctx context.Context, text string) error {
+	text, _ = p.PrepareText(text, false)
 	audioData, err := p.synthesize(ctx, text, false)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+	if err := p.PrepareAudio(p.audioPlayer); err != nil {
+		return err
+	}
+	return p.audioPlayer.PlayWAV(bytes.NewReader(audioData))
 }
 
 func (p *ESpeakProvider) SpeakSSML(ctx context.Context, ssml string) error {
 	if err := p.ValidateSSML(ssml); err != nil {
 		return err
 	}
-	audioData, err := p.synthesize(ctx, ssml, true)
+	audioData, err := p.synthesize(ctx, p.PrepareSSML(ssml), true)
+	if err != nil {
+		return err
+	}
+	if err := p.PrepareAudio(p.audioPlayer); err != nil {
+		return err
+	}
+	return p.audioPlayer.PlayWAV(bytes.NewReader(audioData))
+}
+
+// SpeakStreamed writes the synthesized WAV audio to w instead of playing it
+func (p *ESpeakProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, _ = p.PrepareText(text, false)
+	audioData, err := p.synthesize(ctx, text, false)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SpeakSSMLStreamed writes the synthesized SSML audio to w instead of playing it
+func (p *ESpeakProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
+	if err := p.ValidateSSML(ssml); err != nil {
+		return err
+	}
+	audioData, err := p.synthesize(ctx, p.PrepareSSML(ssml), true)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *ESpeakProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthSSMLToFile synthesizes SSML and writes the audio to filename
+func (p *ESpeakProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakSSMLStreamed(ctx, ssml, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
 }
 
 func (p *ESpeakProvider) GetVoices(ctx context.Context) ([]Voice, error) {
@@ -119,8 +170,19 @@ func (p *ESpeakProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *ESpeakProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
 func (p *ESpeakProvider) SetOutputDevice(deviceID string) error {
-	return ErrNotImplemented
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *ESpeakProvider) Player() *AudioPlayer {
+	return p.audioPlayer
 }
 
 func (p *ESpeakProvider) CheckCredentials(ctx context.Context) bool {
@@ -0,0 +1,116 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// languageSwitchMarker matches the "(en)" style markers eSpeak-NG inserts
+// when it switches language for a word
+var languageSwitchMarker = regexp.MustCompile(`\([a-z]{2,3}(-[a-z0-9]+)*\)`)
+
+type wordSpan struct {
+	text   string
+	offset int
+}
+
+// Phonemize converts text to IPA, X-SAMPA and Kirshenbaum transcriptions,
+// one entry per word of the input. An empty lang uses the provider's voice.
+func (p *ESpeakProvider) Phonemize(ctx context.Context, text, lang string) ([]PhonemizedWord, error) {
+	if lang == "" {
+		lang = p.config.VoiceID
+	}
+
+	words := splitWords(text)
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	joined := make([]string, len(words))
+	for i, w := range words {
+		joined[i] = w.text
+	}
+	ipa, err := p.ipa(ctx, strings.Join(joined, " "), lang)
+	if err != nil {
+		return nil, err
+	}
+
+	// eSpeak expands numbers and abbreviations into several words and can
+	// merge unstressed ones, so fall back to one call per word when the
+	// output does not line up with the input
+	transcriptions := strings.Fields(ipa)
+	if len(transcriptions) != len(words) {
+		transcriptions = make([]string, len(words))
+		for i, w := range words {
+			wordIPA, err := p.ipa(ctx, w.text, lang)
+			if err != nil {
+				return nil, err
+			}
+			transcriptions[i] = strings.Join(strings.Fields(wordIPA), " ")
+		}
+	}
+
+	result := make([]PhonemizedWord, len(words))
+	for i, w := range words {
+		result[i] = PhonemizedWord{
+			Word:        w.text,
+			Offset:      w.offset,
+			IPA:         transcriptions[i],
+			XSAMPA:      IPAToXSAMPA(transcriptions[i]),
+			Kirshenbaum: IPAToKirshenbaum(transcriptions[i]),
+		}
+	}
+	return result, nil
+}
+
+// ipa runs espeak-ng without audio output and returns its IPA transcription
+func (p *ESpeakProvider) ipa(ctx context.Context, text, lang string) (string, error) {
+	args := []string{"-q", "--ipa"}
+	if lang != "" {
+		args = append(args, "-v", lang)
+	}
+
+	cmd := exec.CommandContext(ctx, "espeak-ng", append(args, text)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("espeak-ng failed: %w: %s", err, stderr.String())
+	}
+
+	out := languageSwitchMarker.ReplaceAllString(stdout.String(), "")
+	return strings.TrimSpace(out), nil
+}
+
+// splitWords returns the words of text with their byte offsets. Apostrophes
+// and hyphens inside a word are kept so "don't" stays a single word.
+func splitWords(text string) []wordSpan {
+	var words []wordSpan
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		if !inWord && start >= 0 && (r == '\'' || r == '’' || r == '-') {
+			next, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+			inWord = unicode.IsLetter(next) || unicode.IsDigit(next)
+		}
+
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			words = append(words, wordSpan{text: text[start:i], offset: start})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, wordSpan{text: text[start:], offset: start})
+	}
+	return words
+}
@@ -0,0 +1,55 @@
+package tts
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	words := splitWords("Don't panic, it's 42 — well-known!")
+	want := []wordSpan{
+		{"Don't", 0},
+		{"panic", 6},
+		{"it's", 13},
+		{"42", 18},
+		{"well-known", 25},
+	}
+
+	if len(words) != len(want) {
+		t.Fatalf("Expected %d words, got %d: %v", len(want), len(words), words)
+	}
+	for i := range want {
+		if words[i] != want[i] {
+			t.Errorf("Word %d: expected %+v, got %+v", i, want[i], words[i])
+		}
+	}
+}
+
+func TestESpeakPhonemize(t *testing.T) {
+	if _, err := exec.LookPath("espeak-ng"); err != nil {
+		t.Skip("Skipping test: espeak-ng not installed")
+	}
+
+	provider, err := NewESpeakProvider(TTSConfig{VoiceID: "en-us"})
+	if err != nil {
+		t.Skipf("Skipping test: could not initialize provider: %v", err)
+		return
+	}
+
+	words, err := provider.Phonemize(context.Background(), "Hello world, 42 times", "")
+	if err != nil {
+		t.Fatalf("Phonemize failed: %v", err)
+	}
+	if len(words) != 4 {
+		t.Fatalf("Expected 4 words, got %d", len(words))
+	}
+	for _, w := range words {
+		if w.IPA == "" || w.XSAMPA == "" || w.Kirshenbaum == "" {
+			t.Errorf("Missing transcription for %q: %+v", w.Word, w)
+		}
+	}
+	if words[2].Word != "42" || words[2].Offset != 13 {
+		t.Errorf("Unexpected alignment for number: %+v", words[2])
+	}
+}
@@ -1,7 +1,6 @@
 package tts
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 
@@ -75,24 +74,46 @@ func (p *SherpaProvider) synthesize(ctx context.Context, text string) ([]float32
 }
 
 func (p *SherpaProvider) Speak(ctx context.Context, text string) error {
+	text, _ = p.PrepareText(text, false)
 	samples, err := p.synthesize(ctx, text)
 	if err != nil {
 		return err
 	}
 
-	// Convert float32 samples to MP3
-	audioData, err := encodePCMToMP3(samples, p.tts.SampleRate())
-	if err != nil {
+	if err := p.PreparePlayback(p.audioPlayer); err != nil {
 		return err
 	}
+	return p.audioPlayer.PlayPCM(samples, p.tts.SampleRate(), 1)
+}
 
-	return p.audioPlayer.PlayMP3Stream(bytes.NewReader(audioData))
+// PreparePlayback applies the output settings to player. The model has no
+// rate, pitch or volume controls, so they are applied during playback.
+func (p *SherpaProvider) PreparePlayback(player *AudioPlayer) error {
+	if err := p.PrepareAudio(player); err != nil {
+		return err
+	}
+	p.EmulateProsody(player)
+	return nil
 }
 
 func (p *SherpaProvider) SpeakSSML(ctx context.Context, ssml string) error {
 	return fmt.Errorf("SSML not supported by Sherpa-ONNX")
 }
 
+// SynthToFile synthesizes text and writes the audio to filename as WAV
+func (p *SherpaProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	text, _ = p.PrepareText(text, false)
+	samples, err := p.synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+	return p.WritePCMFile(filename, samples, p.tts.SampleRate(), 1)
+}
+
+func (p *SherpaProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	return fmt.Errorf("SSML not supported by Sherpa-ONNX")
+}
+
 func (p *SherpaProvider) GetVoices(ctx context.Context) ([]Voice, error) {
 	// Sherpa-ONNX uses model files directly, so we just return the currently loaded model
 	voices := []Voice{
@@ -119,8 +140,19 @@ func (p *SherpaProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *SherpaProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
 func (p *SherpaProvider) SetOutputDevice(deviceID string) error {
-	return ErrNotImplemented
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *SherpaProvider) Player() *AudioPlayer {
+	return p.audioPlayer
 }
 
 func (p *SherpaProvider) CheckCredentials(ctx context.Context) bool {
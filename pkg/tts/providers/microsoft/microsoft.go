@@ -5,6 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Microsoft/cognitive-services-speech-sdk-go/common"
 	"github.com/Microsoft/cognitive-services-speech-sdk-go/speech"
@@ -15,6 +19,30 @@ type MicrosoftProvider struct {
 	*BaseProvider
 	config      *speech.SpeechConfig
 	audioPlayer *AudioPlayer
+	style       AzureStyle
+}
+
+// AzureStyle is the speaking style of an Azure neural voice, applied with
+// the mstts:express-as SSML element. Voices support different styles and
+// roles; see the Azure voice list.
+type AzureStyle struct {
+	Style  string  // e.g. "cheerful", "sad" or "newscast"
+	Degree float64 // Intensity from 0.01 to 2; zero keeps the default of 1
+	Role   string  // Role-play, e.g. "Girl" or "OlderAdultMale"
+}
+
+// azureDefaultVoice is named in SSML when no voice is configured, as Azure
+// requires SSML to choose a voice. It speaks many languages.
+const azureDefaultVoice = "en-US-AvaMultilingualNeural"
+
+// azureOutputFormats maps short format names to Azure output formats the
+// player can decode. Full Azure names such as
+// "audio-48khz-192kbitrate-mono-mp3" are also accepted.
+var azureOutputFormats = map[string]common.SpeechSynthesisOutputFormat{
+	"mp3":  common.Audio24Khz48KBitRateMonoMp3,
+	"wav":  common.Riff24Khz16BitMonoPcm,
+	"ogg":  common.Ogg24Khz16BitMonoOpus,
+	"opus": common.Ogg24Khz16BitMonoOpus,
 }
 
 // NewMicrosoftProvider creates a new Azure TTS provider
@@ -23,26 +51,231 @@ func NewMicrosoftProvider(cfg TTSConfig) (*MicrosoftProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := configureSpeech(speechConfig, cfg); err != nil {
+		speechConfig.Close()
+		return nil, err
+	}
 
 	audioPlayer, err := NewAudioPlayer()
 	if err != nil {
 		return nil, err
 	}
 
-	return &MicrosoftProvider{
+	p := &MicrosoftProvider{
 		BaseProvider: NewBaseProvider(cfg),
 		config:       speechConfig,
 		audioPlayer:  audioPlayer,
-	}, nil
+	}
+	// Lexicon SSML needs the full envelope, not a bare speak element
+	p.SetSSMLEnvelope(p.speakElement)
+	return p, nil
+}
+
+// configureSpeech applies the voice and output format from cfg
+func configureSpeech(speechConfig *speech.SpeechConfig, cfg TTSConfig) error {
+	// Report sentence boundaries alongside word boundaries
+	if err := speechConfig.SetPropertyByString("SpeechServiceResponse_RequestSentenceBoundary", "true"); err != nil {
+		return err
+	}
+	if cfg.LanguageCode != "" {
+		if err := speechConfig.SetSpeechSynthesisLanguage(cfg.LanguageCode); err != nil {
+			return err
+		}
+	}
+	if cfg.VoiceID != "" {
+		if err := speechConfig.SetSpeechSynthesisVoiceName(cfg.VoiceID); err != nil {
+			return err
+		}
+	}
+	return setOutputFormat(speechConfig, cfg.OutputFormat)
+}
+
+// setOutputFormat selects the audio format Azure returns, MP3 by default.
+// Ogg/Opus is refused unless the player can decode it.
+func setOutputFormat(speechConfig *speech.SpeechConfig, format string) error {
+	if format == "" {
+		format = "mp3"
+	}
+	lower := strings.ToLower(format)
+	if (lower == "ogg" || strings.Contains(lower, "opus")) && !OpusSupported() {
+		return fmt.Errorf("output format %q needs a build with the opus tag; use mp3 or wav", format)
+	}
+	if f, ok := azureOutputFormats[lower]; ok {
+		return speechConfig.SetSpeechSynthesisOutputFormat(f)
+	}
+	if strings.HasPrefix(format, "raw-") {
+		return fmt.Errorf("output format %q has no header and cannot be played", format)
+	}
+	return speechConfig.SetPropertyByString("SpeechServiceConnection_SynthOutputFormat", format)
+}
+
+// SetProperty handles the Azure-specific "output_format", "style",
+// "style_degree" and "role" properties and passes the rest to BaseProvider
+func (p *MicrosoftProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "output_format":
+		if format, ok := value.(string); ok {
+			return setOutputFormat(p.config, format)
+		}
+	case "style":
+		switch style := value.(type) {
+		case string:
+			p.style.Style = style
+			return nil
+		case AzureStyle:
+			return p.SetStyle(style)
+		}
+	case "style_degree":
+		if degree, ok := value.(float64); ok {
+			style := p.style
+			style.Degree = degree
+			return p.SetStyle(style)
+		}
+	case "role":
+		if role, ok := value.(string); ok {
+			p.style.Role = role
+			return nil
+		}
+	default:
+		return p.BaseProvider.SetProperty(property, value)
+	}
+	return fmt.Errorf("invalid property or value type: %s", property)
+}
+
+// SetStyle sets the speaking style used for subsequent synthesis. The zero
+// AzureStyle speaks in the voice's neutral style.
+func (p *MicrosoftProvider) SetStyle(style AzureStyle) error {
+	if style.Degree != 0 && (style.Degree < 0.01 || style.Degree > 2) {
+		return fmt.Errorf("style degree must be between 0.01 and 2, got %v", style.Degree)
+	}
+	p.style = style
+	return nil
+}
+
+// styledSSML wraps text in the speak and voice elements Azure requires and,
+// if a style is set, in mstts:express-as. SSML that already chooses a voice
+// is sent unchanged.
+func (p *MicrosoftProvider) styledSSML(text string, isSSML bool) (string, bool, error) {
+	styled := p.style != AzureStyle{}
+	if !styled && !isSSML {
+		return text, false, nil
+	}
+	if isSSML && strings.Contains(text, "<voice") {
+		return text, true, nil
+	}
+
+	if styled && p.BaseProvider.config.VoiceID == "" {
+		return "", false, fmt.Errorf("speaking styles require a voice ID")
+	}
+
+	body := escapeXML(text)
+	if isSSML {
+		body = ssmlBody(text)
+	}
+	return p.speakElement(body), true, nil
+}
+
+// speakElement wraps SSML content in the speak element Azure requires, with
+// its version, namespaces, language and a voice, applying the speaking style
+func (p *MicrosoftProvider) speakElement(body string) string {
+	cfg := p.BaseProvider.config
+	voice := cfg.VoiceID
+	if voice == "" {
+		voice = azureDefaultVoice
+	}
+	if p.style != (AzureStyle{}) {
+		attrs := ""
+		if p.style.Style != "" {
+			attrs += fmt.Sprintf(` style="%s"`, escapeXML(p.style.Style))
+		}
+		if p.style.Degree != 0 {
+			attrs += fmt.Sprintf(` styledegree="%s"`, strconv.FormatFloat(p.style.Degree, 'f', -1, 64))
+		}
+		if p.style.Role != "" {
+			attrs += fmt.Sprintf(` role="%s"`, escapeXML(p.style.Role))
+		}
+		body = "<mstts:express-as" + attrs + ">" + body + "</mstts:express-as>"
+	}
+
+	lang := cfg.LanguageCode
+	if lang == "" {
+		lang = "en-US"
+	}
+	return fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" `+
+		`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		escapeXML(lang), escapeXML(voice), body)
+}
+
+// ssmlBody returns the content of the speak element of ssml
+func ssmlBody(ssml string) string {
+	start := strings.Index(ssml, "<speak")
+	if start < 0 {
+		return ssml
+	}
+	open := strings.IndexByte(ssml[start:], '>')
+	end := strings.LastIndex(ssml, "</speak>")
+	if open < 0 || end < start+open {
+		return ssml
+	}
+	return ssml[start+open+1 : end]
 }
 
 func (p *MicrosoftProvider) synthesize(ctx context.Context, text string, isSSML bool) ([]byte, error) {
+	audioData, _, err := p.synthesizeWithTimings(ctx, text, isSSML, nil)
+	return audioData, err
+}
+
+// synthesizeWithTimings synthesizes text and collects the word boundary,
+// viseme and bookmark events of the kinds in collect as timings
+func (p *MicrosoftProvider) synthesizeWithTimings(ctx context.Context, text string, isSSML bool, collect map[TimingKind]bool) ([]byte, []Timing, error) {
+	text, isSSML, err := p.styledSSML(text, isSSML)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	synthesizer, err := speech.NewSpeechSynthesizerFromConfig(p.config, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer synthesizer.Close()
 
+	// The synthesizer calls the handlers from its own threads
+	var mu sync.Mutex
+	var timings []Timing
+	add := func(timing Timing) {
+		mu.Lock()
+		defer mu.Unlock()
+		timings = append(timings, timing)
+	}
+	if collect[WordTiming] || collect[SentenceTiming] {
+		synthesizer.WordBoundary(func(event speech.SpeechSynthesisWordBoundaryEventArgs) {
+			defer event.Close()
+			kind := WordTiming
+			switch event.BoundaryType {
+			case common.PunctuationBoundary:
+				return
+			case common.SentenceBoundary:
+				kind = SentenceTiming
+			}
+			if collect[kind] {
+				start := azureOffset(event.AudioOffset)
+				add(Timing{Kind: kind, Text: event.Text, Start: start, End: start + event.Duration})
+			}
+		})
+	}
+	if collect[VisemeTiming] {
+		synthesizer.VisemeReceived(func(event speech.SpeechSynthesisVisemeEventArgs) {
+			defer event.Close()
+			add(Timing{Kind: VisemeTiming, Text: strconv.Itoa(int(event.VisemeID)), Start: azureOffset(event.AudioOffset)})
+		})
+	}
+	if collect[MarkTiming] {
+		synthesizer.BookmarkReached(func(event speech.SpeechSynthesisBookmarkEventArgs) {
+			defer event.Close()
+			add(Timing{Kind: MarkTiming, Text: event.Text, Start: azureOffset(event.AudioOffset)})
+		})
+	}
+
 	var result *speech.SpeechSynthesisResult
 	if isSSML {
 		result, err = synthesizer.SpeakSsmlAsync(text).Get()
@@ -50,34 +283,110 @@ func (p *MicrosoftProvider) synthesize(ctx context.Context, text string, isSSML
 		result, err = synthesizer.SpeakTextAsync(text).Get()
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer result.Close()
 
 	if result.Reason != common.SynthesizingAudioCompleted {
-		return nil, fmt.Errorf("synthesis failed: %v", result.Reason)
+		return nil, nil, fmt.Errorf("synthesis failed: %v", result.Reason)
 	}
 
-	return result.AudioData, nil
+	mu.Lock()
+	defer mu.Unlock()
+	return result.AudioData, timings, nil
+}
+
+// azureOffset converts an audio offset in 100-nanosecond ticks to a duration
+func azureOffset(ticks uint64) time.Duration {
+	return time.Duration(ticks) * 100 * time.Nanosecond
 }
 
 func (p *MicrosoftProvider) Speak(ctx context.Context, text string) error {
-	audioData, err := p.synthesize(ctx, text, false)
+	text, isSSML := p.PrepareText(text, true)
+	return p.play(ctx, text, isSSML)
+}
+
+func (p *MicrosoftProvider) SpeakSSML(ctx context.Context, ssml string) error {
+	if err := p.ValidateSSML(ssml); err != nil {
+		return err
+	}
+	return p.play(ctx, p.PrepareSSML(ssml), true)
+}
+
+// play synthesizes and plays text, collecting the events connected
+// callbacks need and firing them as playback reaches them
+func (p *MicrosoftProvider) play(ctx context.Context, text string, isSSML bool) error {
+	collect := map[TimingKind]bool{
+		WordTiming:     p.Listening(EventWord),
+		SentenceTiming: p.Listening(EventSentence),
+		VisemeTiming:   p.Listening(EventViseme),
+		MarkTiming:     p.Listening(EventMark),
+	}
+	audioData, timings, err := p.synthesizeWithTimings(ctx, text, isSSML, collect)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(io.NopCloser(bytes.NewReader(audioData)))
+	if err := p.PrepareAudio(p.audioPlayer); err != nil {
+		return err
+	}
+	return p.PlayWithEvents(p.audioPlayer, io.NopCloser(bytes.NewReader(audioData)), timings)
 }
 
-func (p *MicrosoftProvider) SpeakSSML(ctx context.Context, ssml string) error {
+// SpeakStreamed writes the synthesized audio to w instead of playing it
+func (p *MicrosoftProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, isSSML := p.PrepareText(text, true)
+	audioData, err := p.synthesize(ctx, text, isSSML)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SpeakSSMLStreamed writes the synthesized SSML audio to w instead of playing it
+func (p *MicrosoftProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
 	if err := p.ValidateSSML(ssml); err != nil {
 		return err
 	}
-	audioData, err := p.synthesize(ctx, ssml, true)
+	audioData, err := p.synthesize(ctx, p.PrepareSSML(ssml), true)
 	if err != nil {
 		return err
 	}
-	return p.audioPlayer.PlayMP3Stream(io.NopCloser(bytes.NewReader(audioData)))
+	_, err = w.Write(audioData)
+	return err
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *MicrosoftProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthSSMLToFile synthesizes SSML and writes the audio to filename
+func (p *MicrosoftProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakSSMLStreamed(ctx, ssml, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+// SynthToFileWithTimings synthesizes text to filename and returns the word
+// and sentence boundaries reported by the service
+func (p *MicrosoftProvider) SynthToFileWithTimings(ctx context.Context, text, filename string) ([]Timing, error) {
+	text, isSSML := p.PrepareText(text, true)
+	collect := map[TimingKind]bool{WordTiming: true, SentenceTiming: true}
+	audioData, timings, err := p.synthesizeWithTimings(ctx, text, isSSML, collect)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.WriteAudioFile(filename, bytes.NewReader(audioData)); err != nil {
+		return nil, err
+	}
+	return timings, nil
 }
 
 func (p *MicrosoftProvider) GetVoices(ctx context.Context) ([]Voice, error) {
@@ -119,6 +428,21 @@ func (p *MicrosoftProvider) StopAudio() error {
 	return p.audioPlayer.Stop()
 }
 
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *MicrosoftProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
+func (p *MicrosoftProvider) SetOutputDevice(deviceID string) error {
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *MicrosoftProvider) Player() *AudioPlayer {
+	return p.audioPlayer
+}
+
 func (p *MicrosoftProvider) CheckCredentials(ctx context.Context) bool {
 	synthesizer, err := speech.NewSpeechSynthesizerFromConfig(p.config, nil)
 	if err != nil {
@@ -0,0 +1,67 @@
+package tts
+
+import "testing"
+
+func TestMicrosoftStyledSSML(t *testing.T) {
+	provider := &MicrosoftProvider{
+		BaseProvider: NewBaseProvider(TTSConfig{
+			LanguageCode: "en-US",
+			VoiceID:      "en-US-JennyNeural",
+		}),
+	}
+
+	// Plain text without a style is sent as text
+	if got, isSSML, err := provider.styledSSML("Hello", false); err != nil || isSSML || got != "Hello" {
+		t.Errorf("styledSSML() = %q, %v, %v", got, isSSML, err)
+	}
+
+	if err := provider.SetStyle(AzureStyle{Style: "cheerful", Degree: 3}); err == nil {
+		t.Error("SetStyle() accepted a style degree above 2")
+	}
+	if err := provider.SetStyle(AzureStyle{Style: "cheerful", Degree: 1.5, Role: "Girl"}); err != nil {
+		t.Fatalf("SetStyle() error = %v", err)
+	}
+
+	want := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" ` +
+		`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="en-US"><voice name="en-US-JennyNeural">` +
+		`<mstts:express-as style="cheerful" styledegree="1.5" role="Girl">Fish &amp; chips</mstts:express-as></voice></speak>`
+	for _, input := range []struct {
+		text   string
+		isSSML bool
+	}{
+		{"Fish & chips", false},
+		{"<speak>Fish &amp; chips</speak>", true},
+	} {
+		got, isSSML, err := provider.styledSSML(input.text, input.isSSML)
+		if err != nil || !isSSML || got != want {
+			t.Errorf("styledSSML(%q) = %q, %v, %v\nwant %q", input.text, got, isSSML, err, want)
+		}
+	}
+
+	// SSML that already chooses a voice is left alone
+	ssml := "<speak version='1.0'><voice name='en-US-GuyNeural'>Hi</voice></speak>"
+	if got, _, _ := provider.styledSSML(ssml, true); got != ssml {
+		t.Errorf("styledSSML() changed SSML with a voice: %q", got)
+	}
+}
+
+func TestMicrosoftLexiconSSML(t *testing.T) {
+	provider := &MicrosoftProvider{BaseProvider: NewBaseProvider(TTSConfig{LanguageCode: "en-GB"})}
+	provider.SetSSMLEnvelope(provider.speakElement)
+
+	lex := NewLexicon("test", "en-GB", "ipa")
+	lex.Add("SQL", "", "sequel")
+	provider.SetLexicon(lex)
+
+	// Without a voice ID the default voice is named, as Azure requires
+	want := `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" ` +
+		`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="en-GB"><voice name="` + azureDefaultVoice + `">` +
+		`<sub alias="sequel">SQL</sub></voice></speak>`
+	got, isSSML := provider.PrepareText("SQL", true)
+	if !isSSML || got != want {
+		t.Errorf("PrepareText() = %q, %v\nwant %q", got, isSSML, want)
+	}
+	if styled, _, err := provider.styledSSML(got, true); err != nil || styled != got {
+		t.Errorf("styledSSML() changed lexicon SSML: %q, %v", styled, err)
+	}
+}
@@ -1,100 +1,329 @@
 package tts
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
-	"net/http/httptest"
-	"testing"
+	"sort"
+	"strings"
 )
 
-func TestWitAIProvider(t *testing.T) {
-	// Mock HTTP server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch r.URL.Path {
-		case "/synthesize":
-			// Return mock MP3 data
-			w.Header().Set("Content-Type", "audio/mpeg")
-			w.Write([]byte("mock mp3 data"))
-		case "/synthesize/voices":
-			// Return mock voices data
-			w.Header().Set("Content-Type", "application/json")
-			w.Write([]byte(`[
-				{
-					"id": "voice1",
-					"name": "Test Voice",
-					"locale": "en-US",
-					"gender": "female",
-					"style": "natural",
-					"category": "standard"
-				}
-			]`))
-		}
-	}))
-	defer server.Close()
-
-	// Create provider with mock server URL
-	witAIBaseURL = server.URL + "/synthesize"
-	provider, err := NewWitAIProvider(TTSConfig{
-		APIKey:  "test-key",
-		VoiceID: "voice1",
-	})
+// witAIBaseURL is the root of the Wit.ai API, replaced in tests
+var witAIBaseURL = "https://api.wit.ai"
+
+// witAIVersion dates the API behaviour the provider expects
+const witAIVersion = "20240304"
+
+// witAIFormats maps output formats to the Accept types Wit.ai returns
+var witAIFormats = map[string]string{
+	"":    "audio/mpeg",
+	"mp3": "audio/mpeg",
+	"wav": "audio/wav",
+}
+
+// WitAIProvider implements TTSProvider for Wit.ai
+type WitAIProvider struct {
+	*BaseProvider
+	apiKey      string
+	client      *http.Client
+	audioPlayer *AudioPlayer
+	accept      string
+	style       string // e.g. "soft" or "formal"; empty uses the voice default
+}
+
+// NewWitAIProvider creates a new Wit.ai provider. TTSConfig.APIKey is the
+// app's server access token and TTSConfig.OutputFormat is "mp3" or "wav".
+func NewWitAIProvider(cfg TTSConfig) (*WitAIProvider, error) {
+	accept, ok := witAIFormats[cfg.OutputFormat]
+	if !ok {
+		return nil, fmt.Errorf("unsupported Wit.ai output format %q", cfg.OutputFormat)
+	}
+
+	audioPlayer, err := NewAudioPlayer()
 	if err != nil {
-		t.Fatalf("Failed to create provider: %v", err)
+		return nil, err
 	}
 
-	t.Run("GetVoices", func(t *testing.T) {
-		voices, err := provider.GetVoices(context.Background())
-		if err != nil {
-			t.Errorf("GetVoices failed: %v", err)
-		}
-		if len(voices) != 1 {
-			t.Errorf("Expected 1 voice, got %d", len(voices))
+	return &WitAIProvider{
+		BaseProvider: NewBaseProvider(cfg),
+		apiKey:       cfg.APIKey,
+		client:       &http.Client{},
+		audioPlayer:  audioPlayer,
+		accept:       accept,
+	}, nil
+}
+
+// SetProperty handles the Wit.ai-specific "style" (string) and "speed"
+// (int percent, 100 is normal) properties and passes the rest to
+// BaseProvider. "speed" is another way of setting "rate".
+func (p *WitAIProvider) SetProperty(property string, value interface{}) error {
+	switch property {
+	case "style":
+		if style, ok := value.(string); ok {
+			p.style = style
+			return nil
 		}
-		if voices[0].ID != "voice1" {
-			t.Errorf("Expected voice ID 'voice1', got '%s'", voices[0].ID)
+	case "speed":
+		if speed, ok := value.(int); ok {
+			if speed < 10 || speed > 400 {
+				return fmt.Errorf("speed must be between 10 and 400, got %d", speed)
+			}
+			p.audioConfig.Rate = float64(speed) / 100
+			return nil
 		}
-	})
+	default:
+		return p.BaseProvider.SetProperty(property, value)
+	}
+	return fmt.Errorf("invalid property or value type: %s", property)
+}
 
-	t.Run("SSML not supported", func(t *testing.T) {
-		err := provider.SpeakSSML(context.Background(), "<speak>Hello</speak>")
-		if err == nil {
-			t.Error("Expected error for SSML, got nil")
-		}
-	})
+type witAIRequest struct {
+	Query string `json:"q"`
+	Voice string `json:"voice"`
+	Style string `json:"style,omitempty"`
+	Speed int    `json:"speed"` // Percent of normal, from 10 to 400
+	Pitch int    `json:"pitch"` // Percent of normal, from 25 to 400
+}
 
-	t.Run("CheckCredentials", func(t *testing.T) {
-		if !provider.CheckCredentials(context.Background()) {
-			t.Error("CheckCredentials returned false, expected true")
-		}
-	})
+// witAIPercent converts a rate or pitch ratio to the percent the API
+// expects, clamped to its range. Zero or less is treated as normal.
+func witAIPercent(ratio float64, lo, hi int) int {
+	if ratio <= 0 {
+		return 100
+	}
+	return min(max(int(math.Round(ratio*100)), lo), hi)
 }
 
-func TestWitAIProviderErrors(t *testing.T) {
-	// Mock server that returns errors
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusUnauthorized)
-	}))
-	defer server.Close()
+// witAIVoice is a voice as listed by the voices endpoint
+type witAIVoice struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Locale string   `json:"locale"`
+	Gender string   `json:"gender"`
+	Styles []string `json:"styles"`
+}
 
-	witAIBaseURL = server.URL + "/synthesize"
-	provider, err := NewWitAIProvider(TTSConfig{
-		APIKey:  "invalid-key",
-		VoiceID: "voice1",
+// newRequest builds an authorized API request for path
+func (p *WitAIProvider) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, witAIBaseURL+path+"?v="+witAIVersion, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	return req, nil
+}
+
+// do sends req and returns the successful response
+func (p *WitAIProvider) do(req *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return resp, nil
+}
+
+// synthesize returns the response body, which streams the audio
+func (p *WitAIProvider) synthesize(ctx context.Context, text string) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(witAIRequest{
+		Query: text,
+		Voice: p.config.VoiceID,
+		Style: p.style,
+		Speed: witAIPercent(p.audioConfig.Rate, 10, 400),
+		Pitch: witAIPercent(p.audioConfig.Pitch, 25, 400),
 	})
 	if err != nil {
-		t.Fatalf("Failed to create provider: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := p.newRequest(ctx, "POST", "/synthesize", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", p.accept)
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (p *WitAIProvider) Speak(ctx context.Context, text string) error {
+	text, _ = p.PrepareText(text, false)
+	audio, err := p.synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+
+	// The player closes the response body once it has been decoded
+	if err := p.PreparePlayback(p.audioPlayer); err != nil {
+		audio.Close()
+		return err
+	}
+	return p.audioPlayer.Play(audio)
+}
+
+// PreparePlayback applies the output settings to player. Rate and pitch are
+// sent to the service; it has no volume setting, so only the volume is
+// applied during playback.
+func (p *WitAIProvider) PreparePlayback(player *AudioPlayer) error {
+	if err := p.PrepareAudio(player); err != nil {
+		return err
 	}
+	player.SetEffects(AudioEffects{Speed: 1, Pitch: 1, Gain: p.audioConfig.Volume})
+	return nil
+}
 
-	t.Run("Invalid credentials", func(t *testing.T) {
-		if provider.CheckCredentials(context.Background()) {
-			t.Error("CheckCredentials returned true with invalid key")
+func (p *WitAIProvider) SpeakSSML(ctx context.Context, ssml string) error {
+	return fmt.Errorf("SSML not supported by Wit.ai")
+}
+
+// SpeakStreamed writes the synthesized audio to w as it arrives
+func (p *WitAIProvider) SpeakStreamed(ctx context.Context, text string, w io.Writer) error {
+	text, _ = p.PrepareText(text, false)
+	audio, err := p.synthesize(ctx, text)
+	if err != nil {
+		return err
+	}
+	defer audio.Close()
+
+	_, err = io.Copy(w, audio)
+	return err
+}
+
+func (p *WitAIProvider) SpeakSSMLStreamed(ctx context.Context, ssml string, w io.Writer) error {
+	return fmt.Errorf("SSML not supported by Wit.ai")
+}
+
+// SynthToFile synthesizes text and writes the audio to filename
+func (p *WitAIProvider) SynthToFile(ctx context.Context, text, filename string) error {
+	var buf bytes.Buffer
+	if err := p.SpeakStreamed(ctx, text, &buf); err != nil {
+		return err
+	}
+	return p.WriteAudioFile(filename, &buf)
+}
+
+func (p *WitAIProvider) SynthSSMLToFile(ctx context.Context, ssml, filename string) error {
+	return fmt.Errorf("SSML not supported by Wit.ai")
+}
+
+// ValidateSSML always fails, as Wit.ai synthesizes plain text only
+func (p *WitAIProvider) ValidateSSML(ssml string) error {
+	return fmt.Errorf("SSML not supported by Wit.ai")
+}
+
+// listVoices fetches the voices endpoint, which groups voices by locale
+func (p *WitAIProvider) listVoices(ctx context.Context) ([]witAIVoice, error) {
+	req, err := p.newRequest(ctx, "GET", "/voices", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode voices: %w", err)
+	}
+
+	// Accept a flat list as well as the grouped form
+	var voices []witAIVoice
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(raw, &voices); err != nil {
+			return nil, fmt.Errorf("failed to decode voices: %w", err)
+		}
+		return voices, nil
+	}
+
+	var byLocale map[string][]witAIVoice
+	if err := json.Unmarshal(raw, &byLocale); err != nil {
+		return nil, fmt.Errorf("failed to decode voices: %w", err)
+	}
+	for _, group := range byLocale {
+		voices = append(voices, group...)
+	}
+	sort.Slice(voices, func(i, j int) bool {
+		if voices[i].Locale != voices[j].Locale {
+			return voices[i].Locale < voices[j].Locale
 		}
+		return voices[i].Name < voices[j].Name
 	})
+	return voices, nil
+}
+
+func (p *WitAIProvider) GetVoices(ctx context.Context) ([]Voice, error) {
+	result, err := p.listVoices(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	t.Run("Synthesis failure", func(t *testing.T) {
-		err := provider.Speak(context.Background(), "Hello")
-		if err == nil {
-			t.Error("Expected error for synthesis with invalid key, got nil")
+	voices := make([]Voice, 0, len(result))
+	for _, v := range result {
+		id := v.ID
+		if id == "" {
+			id = v.Name
 		}
+		voices = append(voices, Voice{
+			ID:          id,
+			Name:        v.Name,
+			Language:    strings.ReplaceAll(v.Locale, "_", "-"),
+			Gender:      v.Gender,
+			Provider:    "WitAI",
+			NativeVoice: v,
+		})
+	}
+	return voices, nil
+}
+
+// Audio control methods
+func (p *WitAIProvider) PauseAudio() error {
+	return p.audioPlayer.Pause()
+}
+
+func (p *WitAIProvider) ResumeAudio() error {
+	return p.audioPlayer.Resume()
+}
+
+func (p *WitAIProvider) StopAudio() error {
+	return p.audioPlayer.Stop()
+}
+
+// SetAudioSink routes playback to sink, e.g. a null sink on headless servers
+func (p *WitAIProvider) SetAudioSink(sink AudioSink) error {
+	return p.audioPlayer.SetSink(sink)
+}
+
+// SetOutputDevice routes playback to the output device with the given ID
+func (p *WitAIProvider) SetOutputDevice(deviceID string) error {
+	return p.audioPlayer.SetOutputDevice(deviceID)
+}
+
+// Player returns the audio player, for live playback controls
+func (p *WitAIProvider) Player() *AudioPlayer {
+	return p.audioPlayer
+}
+
+func (p *WitAIProvider) CheckCredentials(ctx context.Context) bool {
+	_, err := p.listVoices(ctx)
+	return err == nil
+}
+
+func init() {
+	RegisterProvider(ProviderWitAI, func(cfg TTSConfig) (TTSProvider, error) {
+		return NewWitAIProvider(cfg)
 	})
 }
@@ -0,0 +1,175 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWitAIProvider(t *testing.T) {
+	// Mock HTTP server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/synthesize":
+			// Return mock MP3 data
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write([]byte("mock mp3 data"))
+		case "/synthesize/voices":
+			// Return mock voices data
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[
+				{
+					"id": "voice1",
+					"name": "Test Voice",
+					"locale": "en-US",
+					"gender": "female",
+					"style": "natural",
+					"category": "standard"
+				}
+			]`))
+		}
+	}))
+	defer server.Close()
+
+	// Create provider with mock server URL
+	witAIBaseURL = server.URL + "/synthesize"
+	provider, err := NewWitAIProvider(TTSConfig{
+		APIKey:  "test-key",
+		VoiceID: "voice1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	t.Run("GetVoices", func(t *testing.T) {
+		voices, err := provider.GetVoices(context.Background())
+		if err != nil {
+			t.Errorf("GetVoices failed: %v", err)
+		}
+		if len(voices) != 1 {
+			t.Errorf("Expected 1 voice, got %d", len(voices))
+		}
+		if voices[0].ID != "voice1" {
+			t.Errorf("Expected voice ID 'voice1', got '%s'", voices[0].ID)
+		}
+	})
+
+	t.Run("SSML not supported", func(t *testing.T) {
+		err := provider.SpeakSSML(context.Background(), "<speak>Hello</speak>")
+		if err == nil {
+			t.Error("Expected error for SSML, got nil")
+		}
+	})
+
+	t.Run("CheckCredentials", func(t *testing.T) {
+		if !provider.CheckCredentials(context.Background()) {
+			t.Error("CheckCredentials returned false, expected true")
+		}
+	})
+}
+
+func TestWitAIProviderErrors(t *testing.T) {
+	// Mock server that returns errors
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	witAIBaseURL = server.URL + "/synthesize"
+	provider, err := NewWitAIProvider(TTSConfig{
+		APIKey:  "invalid-key",
+		VoiceID: "voice1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+
+	t.Run("Invalid credentials", func(t *testing.T) {
+		if provider.CheckCredentials(context.Background()) {
+			t.Error("CheckCredentials returned true with invalid key")
+		}
+	})
+
+	t.Run("Synthesis failure", func(t *testing.T) {
+		err := provider.Speak(context.Background(), "Hello")
+		if err == nil {
+			t.Error("Expected error for synthesis with invalid key, got nil")
+		}
+	})
+}
+
+func TestWitAIRequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/synthesize":
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["q"] != "Hello" || body["voice"] != "Rebecca" || body["style"] != "soft" ||
+				body["speed"] != 150.0 || body["pitch"] != 400.0 {
+				t.Errorf("unexpected request body: %v", body)
+			}
+			if r.Header.Get("Accept") != "audio/wav" {
+				t.Errorf("Accept = %q, want audio/wav", r.Header.Get("Accept"))
+			}
+			w.Write([]byte("mock wav data"))
+		case "/voices":
+			// The service groups voices by locale
+			w.Write([]byte(`{
+				"en_US": [{"name": "Rebecca", "locale": "en_US", "gender": "female", "styles": ["default", "soft"]}],
+				"en_GB": [{"name": "Colin", "locale": "en_GB", "gender": "male", "styles": ["default"]}]
+			}`))
+		}
+	}))
+	defer server.Close()
+
+	witAIBaseURL = server.URL
+	provider, err := NewWitAIProvider(TTSConfig{APIKey: "test-key", VoiceID: "Rebecca", OutputFormat: "wav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.SetProperty("speed", 500); err == nil {
+		t.Error("expected an error for a speed above 400")
+	}
+	if err := provider.SetProperty("style", "soft"); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.SetProperty("speed", 150); err != nil {
+		t.Fatal(err)
+	}
+	// Pitch is sent natively, clamped to the service's range
+	if err := provider.SetProperty("pitch", 5.0); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.SetProperty("volume", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.PreparePlayback(provider.Player()); err != nil {
+		t.Fatal(err)
+	}
+	if fx := provider.Player().Effects(); fx != (AudioEffects{Speed: 1, Pitch: 1, Gain: 0.5}) {
+		t.Errorf("only volume should be applied during playback, got %+v", fx)
+	}
+
+	var buf bytes.Buffer
+	if err := provider.SpeakStreamed(context.Background(), "Hello", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "mock wav data" {
+		t.Errorf("audio = %q", buf.String())
+	}
+
+	voices, err := provider.GetVoices(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(voices) != 2 || voices[0].ID != "Colin" || voices[1].Language != "en-US" {
+		t.Errorf("unexpected voices: %+v", voices)
+	}
+}
@@ -0,0 +1,337 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FlushPolicy controls when text buffered from a stream is sent for synthesis
+type FlushPolicy struct {
+	Punctuation string        // Characters that end a segment when followed by whitespace
+	MinChars    int           // Segments shorter than this are not flushed on punctuation
+	MaxChars    int           // Flush at the last word boundary once this many characters are buffered
+	MaxLatency  time.Duration // Flush whatever is buffered after waiting this long for a boundary
+}
+
+// DefaultFlushPolicy returns a policy that segments on sentence punctuation
+func DefaultFlushPolicy() FlushPolicy {
+	return FlushPolicy{
+		Punctuation: ".!?;:",
+		MinChars:    20,
+		MaxChars:    250,
+		MaxLatency:  1500 * time.Millisecond,
+	}
+}
+
+// SegmentStream groups incoming text tokens into sentence-sized segments
+// according to the flush policy. The returned channel is closed once tokens
+// is closed and the remaining text has been flushed, or ctx is done.
+func SegmentStream(ctx context.Context, tokens <-chan string, policy FlushPolicy) <-chan string {
+	texts := make(chan string)
+	go func() {
+		defer close(texts)
+		for segment := range segmentStream(ctx, tokens, policy) {
+			select {
+			case texts <- segment.text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return texts
+}
+
+// streamSegment is a segment of streamed text
+type streamSegment struct {
+	text      string
+	paragraph bool // a line break separates it from the previous segment
+}
+
+func segmentStream(ctx context.Context, tokens <-chan string, policy FlushPolicy) <-chan streamSegment {
+	segments := make(chan streamSegment)
+
+	go func() {
+		defer close(segments)
+
+		var buf strings.Builder
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		lineBreak := false // seen since the last segment
+
+		emit := func(raw string) bool {
+			start := strings.IndexFunc(raw, func(r rune) bool { return !unicode.IsSpace(r) })
+			if start < 0 {
+				lineBreak = lineBreak || strings.Contains(raw, "\n")
+				return true
+			}
+			end := len(strings.TrimRightFunc(raw, unicode.IsSpace))
+			segment := streamSegment{
+				text:      raw[start:end],
+				paragraph: lineBreak || strings.Contains(raw[:start], "\n"),
+			}
+			lineBreak = strings.Contains(raw[end:], "\n")
+
+			select {
+			case segments <- segment:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		stopTimer := func() {
+			if timer != nil {
+				timer.Stop()
+				timer, timeout = nil, nil
+			}
+		}
+		defer stopTimer()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case token, ok := <-tokens:
+				if !ok {
+					emit(buf.String())
+					return
+				}
+				// Whitespace left by a flush doesn't start the timer, so check it directly
+				if timer == nil && policy.MaxLatency > 0 {
+					timer = time.NewTimer(policy.MaxLatency)
+					timeout = timer.C
+				}
+				buf.WriteString(token)
+
+				for {
+					text := buf.String()
+					cut := policy.cutPoint(text)
+					if cut <= 0 {
+						break
+					}
+					if !emit(text[:cut]) {
+						return
+					}
+					buf.Reset()
+					buf.WriteString(text[cut:])
+					stopTimer()
+					if strings.TrimSpace(buf.String()) != "" && policy.MaxLatency > 0 {
+						timer = time.NewTimer(policy.MaxLatency)
+						timeout = timer.C
+					}
+				}
+
+			case <-timeout:
+				timer, timeout = nil, nil
+				text := buf.String()
+				// Keep a trailing partial word for the next segment
+				cut := strings.LastIndexFunc(text, unicode.IsSpace)
+				if cut <= 0 {
+					cut = len(text)
+				}
+				if !emit(text[:cut]) {
+					return
+				}
+				buf.Reset()
+				buf.WriteString(text[cut:])
+				if buf.Len() > 0 && policy.MaxLatency > 0 {
+					timer = time.NewTimer(policy.MaxLatency)
+					timeout = timer.C
+				}
+			}
+		}
+	}()
+
+	return segments
+}
+
+// cutPoint returns the byte offset at which text should be split, or 0 if
+// more text is needed
+func (f FlushPolicy) cutPoint(text string) int {
+	chars := 0
+	for i, r := range text {
+		chars++
+		if r == '\n' && chars >= f.MinChars {
+			return i + 1
+		}
+		if !strings.ContainsRune(f.Punctuation, r) || chars < f.MinChars {
+			continue
+		}
+		// Require whitespace after the punctuation so "3.14" or "e.g." mid-token
+		// is not treated as a sentence end
+		next, size := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):])
+		if size > 0 && unicode.IsSpace(next) {
+			return i + utf8.RuneLen(r)
+		}
+	}
+
+	if f.MaxChars > 0 && chars >= f.MaxChars {
+		limit := len(text)
+		n := 0
+		for i := range text {
+			if n == f.MaxChars {
+				limit = i
+				break
+			}
+			n++
+		}
+		if cut := strings.LastIndexFunc(text[:limit], unicode.IsSpace); cut > 0 {
+			return cut
+		}
+		return limit
+	}
+	return 0
+}
+
+// audioPreparer is implemented by providers that embed BaseProvider
+type audioPreparer interface {
+	PreparePlayback(player *AudioPlayer) error
+	Gaps() Gaps
+}
+
+// SpeakFromStream speaks text as it arrives on tokens, for example from a
+// language model. The first segment is synthesized as soon as the flush
+// policy allows, and each following segment is synthesized while the
+// previous one plays. Segments are queued on the provider's own player, so
+// they play back to back with the provider's output settings, separated by
+// its sentence and paragraph gaps. It returns once all text has been spoken.
+func SpeakFromStream(ctx context.Context, provider TTSProvider, tokens <-chan string, policy FlushPolicy) error {
+	pp, ok := provider.(PlayerProvider)
+	if !ok {
+		return fmt.Errorf("provider %T does not expose an audio player", provider)
+	}
+	player := pp.Player()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := segmentStream(ctx, tokens, policy)
+
+	// Buffer one synthesized segment ahead of playback
+	type clip struct {
+		audio     []byte
+		paragraph bool
+	}
+	clips := make(chan clip, 1)
+	synthErr := make(chan error, 1)
+	go func() {
+		defer close(clips)
+		for segment := range segments {
+			var buf bytes.Buffer
+			if err := provider.SpeakStreamed(ctx, segment.text, &buf); err != nil {
+				synthErr <- err
+				return
+			}
+			select {
+			case clips <- clip{buf.Bytes(), segment.paragraph}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var gaps Gaps
+	if p, ok := provider.(audioPreparer); ok {
+		if err := p.PreparePlayback(player); err != nil {
+			return err
+		}
+		gaps = p.Gaps()
+	}
+
+	// The first segment follows anything already playing after the utterance gap
+	first := true
+	for c := range clips {
+		if first {
+			player.SetGap(gaps.Utterance)
+			first = false
+		} else if c.paragraph {
+			player.SetGap(gaps.Paragraph)
+		} else {
+			player.SetGap(gaps.Sentence)
+		}
+		if err := player.Enqueue(bytes.NewReader(c.audio)); err != nil {
+			player.Stop()
+			return err
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- player.WaitForCompletion() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		player.Stop()
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-synthErr:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+// SpeakFromReader speaks text read incrementally from r
+func SpeakFromReader(ctx context.Context, provider TTSProvider, r io.Reader, policy FlushPolicy) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tokens := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(tokens)
+		buf := make([]byte, 512)
+		var pending []byte
+		for {
+			n, err := r.Read(buf)
+			pending = append(pending, buf[:n]...)
+
+			// Hold back an incomplete UTF-8 sequence until the next read
+			valid := len(pending)
+			for valid > 0 && !utf8.Valid(pending[:valid]) && len(pending)-valid < utf8.UTFMax {
+				valid--
+			}
+			if valid > 0 {
+				select {
+				case tokens <- string(pending[:valid]):
+				case <-ctx.Done():
+					return
+				}
+				pending = append(pending[:0], pending[valid:]...)
+			}
+
+			if err == io.EOF {
+				if len(pending) > 0 {
+					select {
+					case tokens <- string(pending):
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			if err != nil {
+				readErr <- err
+				cancel()
+				return
+			}
+		}
+	}()
+
+	speakErr := SpeakFromStream(ctx, provider, tokens, policy)
+	select {
+	case err := <-readErr:
+		return err
+	default:
+	}
+	return speakErr
+}
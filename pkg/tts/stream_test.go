@@ -0,0 +1,122 @@
+package tts_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func collectSegments(t *testing.T, policy tts.FlushPolicy, tokens ...string) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, tok := range tokens {
+			in <- tok
+		}
+	}()
+
+	var segments []string
+	for s := range tts.SegmentStream(ctx, in, policy) {
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+func TestSegmentStream(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy tts.FlushPolicy
+		tokens []string
+		want   []string
+	}{
+		{
+			name:   "sentence punctuation",
+			policy: tts.FlushPolicy{Punctuation: ".!?"},
+			tokens: []string{"Hel", "lo there", ". How", " are you", "? Fine"},
+			want:   []string{"Hello there.", "How are you?", "Fine"},
+		},
+		{
+			name:   "decimal point is not a boundary",
+			policy: tts.FlushPolicy{Punctuation: "."},
+			tokens: []string{"Pi is 3", ".14 roughly", ". Done"},
+			want:   []string{"Pi is 3.14 roughly.", "Done"},
+		},
+		{
+			name:   "minimum length merges short sentences",
+			policy: tts.FlushPolicy{Punctuation: ".", MinChars: 10},
+			tokens: []string{"Hi. ", "Yes. ", "That is all. ", "End"},
+			want:   []string{"Hi. Yes. That is all.", "End"},
+		},
+		{
+			name:   "maximum length splits at a word boundary",
+			policy: tts.FlushPolicy{MaxChars: 12},
+			tokens: []string{"one two three four five"},
+			want:   []string{"one two", "three four", "five"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := collectSegments(t, tc.policy, tc.tokens...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSegmentStreamMaxLatency(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	in := make(chan string)
+	segments := tts.SegmentStream(ctx, in, tts.FlushPolicy{
+		Punctuation: ".",
+		MaxLatency:  50 * time.Millisecond,
+	})
+
+	in <- "no punctuation yet but a parti"
+	select {
+	case s := <-segments:
+		if s != "no punctuation yet but a" {
+			t.Errorf("Unexpected latency flush: %q", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected flush after MaxLatency")
+	}
+
+	in <- "al word."
+	close(in)
+	if s := <-segments; s != "partial word." {
+		t.Errorf("Expected held-back word to be flushed with the rest, got %q", s)
+	}
+
+	// Whitespace left after a sentence still starts the timer for the next one
+	in = make(chan string)
+	segments = tts.SegmentStream(ctx, in, tts.FlushPolicy{
+		Punctuation: ".",
+		MaxLatency:  50 * time.Millisecond,
+	})
+	defer close(in)
+
+	in <- "done. "
+	if s := <-segments; s != "done." {
+		t.Errorf("Unexpected sentence: %q", s)
+	}
+	in <- "still going"
+	select {
+	case s := <-segments:
+		if s != "still" {
+			t.Errorf("Unexpected latency flush: %q", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected flush after MaxLatency following a trailing space")
+	}
+}
@@ -0,0 +1,368 @@
+package tts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	playback "github.com/willwade/go-tts-wrapper/internal/audio"
+	"github.com/willwade/go-tts-wrapper/pkg/audio"
+)
+
+// TimingKind identifies what a Timing marks
+type TimingKind string
+
+const (
+	WordTiming     TimingKind = "word"
+	SentenceTiming TimingKind = "sentence"
+	VisemeTiming   TimingKind = "viseme" // Text is the viseme code
+	MarkTiming     TimingKind = "mark"   // Text is the name of an SSML <mark>
+)
+
+// Timing marks when a word, sentence, viseme or SSML mark occurs in
+// synthesized audio. End is zero for marks without a duration.
+type Timing struct {
+	Kind  TimingKind
+	Text  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// TimedSynthesizer is implemented by providers that report word or sentence
+// boundaries while synthesizing
+type TimedSynthesizer interface {
+	SynthToFileWithTimings(ctx context.Context, text, filename string) ([]Timing, error)
+}
+
+// SubtitleOptions control how timings are grouped into subtitle cues
+type SubtitleOptions struct {
+	MaxLineLength     int           // Characters per line
+	MaxLines          int           // Lines per cue
+	MaxCharsPerSecond float64       // Reading speed; short cues are held on screen longer
+	MinDuration       time.Duration // Shortest time a cue is shown
+	MaxDuration       time.Duration // Longest span of speech in one cue
+}
+
+// DefaultSubtitleOptions returns the line length and reading speed commonly
+// used for broadcast subtitles
+func DefaultSubtitleOptions() SubtitleOptions {
+	return SubtitleOptions{
+		MaxLineLength:     42,
+		MaxLines:          2,
+		MaxCharsPerSecond: 17,
+		MinDuration:       time.Second,
+		MaxDuration:       7 * time.Second,
+	}
+}
+
+// Cue is one subtitle
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Lines []string
+}
+
+// Relative length of the pauses after punctuation, in characters
+const (
+	sentencePause = 4
+	clausePause   = 2
+)
+
+// EstimateTimings spreads the words of text over [start, end) in proportion
+// to their length, allowing for pauses after punctuation. It stands in for
+// word boundaries when a provider does not report them.
+func EstimateTimings(text string, start, end time.Duration) []Timing {
+	words := strings.Fields(text)
+	if len(words) == 0 || end <= start {
+		return nil
+	}
+
+	var total int
+	for i, word := range words {
+		total += utf8.RuneCountInString(word)
+		if i < len(words)-1 {
+			total += pauseAfter(word)
+		}
+	}
+	perChar := float64(end-start) / float64(total)
+
+	timings := make([]Timing, len(words))
+	pos := 0
+	for i, word := range words {
+		at := func(chars int) time.Duration {
+			return start + time.Duration(float64(chars)*perChar)
+		}
+		timings[i] = Timing{Kind: WordTiming, Text: word, Start: at(pos)}
+		pos += utf8.RuneCountInString(word)
+		timings[i].End = at(pos)
+		pos += pauseAfter(word)
+	}
+	timings[len(timings)-1].End = end
+	return timings
+}
+
+// pauseAfter returns the pause expected after word
+func pauseAfter(word string) int {
+	switch {
+	case endsSentence(word):
+		return sentencePause
+	case strings.ContainsAny(word[len(word)-1:], ",;:"):
+		return clausePause
+	}
+	return 0
+}
+
+// endsSentence reports whether word ends with sentence punctuation,
+// allowing for closing quotes and brackets
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, "\"')]}”’")
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") ||
+		strings.HasSuffix(word, "?") || strings.HasSuffix(word, "…")
+}
+
+// BuildCues groups word timings into subtitle cues. Cues break at sentence
+// ends and whenever the text would not fit the line limits or the speech
+// would last too long. When text is given, the words are matched against it
+// so punctuation dropped by the provider is shown. Sentence timings are
+// split into estimated words if a provider reports no word timings.
+func BuildCues(text string, timings []Timing, opts SubtitleOptions) []Cue {
+	words := wordTimings(timings)
+	if text != "" {
+		words = alignWords(text, words)
+	}
+
+	var cues []Cue
+	var current []Timing
+	closeCue := func() {
+		if len(current) == 0 {
+			return
+		}
+		texts := make([]string, len(current))
+		for i, w := range current {
+			texts[i] = w.Text
+		}
+		cues = append(cues, Cue{
+			Start: current[0].Start,
+			End:   current[len(current)-1].End,
+			Lines: wrapWords(texts, opts.MaxLineLength),
+		})
+		current = nil
+	}
+
+	for _, w := range words {
+		if len(current) > 0 {
+			texts := make([]string, 0, len(current)+1)
+			for _, c := range current {
+				texts = append(texts, c.Text)
+			}
+			texts = append(texts, w.Text)
+			tooLong := opts.MaxDuration > 0 && w.End-current[0].Start > opts.MaxDuration
+			tooWide := opts.MaxLines > 0 && len(wrapWords(texts, opts.MaxLineLength)) > opts.MaxLines
+			if tooLong || tooWide {
+				closeCue()
+			}
+		}
+		current = append(current, w)
+		if endsSentence(w.Text) {
+			closeCue()
+		}
+	}
+	closeCue()
+
+	// Hold cues long enough to be read, without overlapping the next
+	for i := range cues {
+		need := opts.MinDuration
+		if opts.MaxCharsPerSecond > 0 {
+			chars := utf8.RuneCountInString(strings.Join(cues[i].Lines, " "))
+			need = max(need, time.Duration(float64(chars)/opts.MaxCharsPerSecond*float64(time.Second)))
+		}
+		end := cues[i].Start + need
+		if i+1 < len(cues) {
+			end = min(end, cues[i+1].Start)
+		}
+		cues[i].End = max(cues[i].End, end)
+	}
+	return cues
+}
+
+// wordTimings returns the word timings, estimating words within sentences
+// if there are none
+func wordTimings(timings []Timing) []Timing {
+	var words []Timing
+	for _, t := range timings {
+		if t.Kind == WordTiming {
+			words = append(words, t)
+		}
+	}
+	if len(words) > 0 {
+		return words
+	}
+	for _, t := range timings {
+		if t.Kind == SentenceTiming {
+			words = append(words, EstimateTimings(t.Text, t.Start, t.End)...)
+		}
+	}
+	return words
+}
+
+// alignWords replaces the text of each word with the matching text from the
+// source, up to the next space or word, so attached punctuation is kept
+func alignWords(text string, words []Timing) []Timing {
+	positions := make([]int, len(words))
+	cursor := 0
+	for i, w := range words {
+		positions[i] = -1
+		if w.Text == "" {
+			continue
+		}
+		if idx := strings.Index(text[cursor:], w.Text); idx >= 0 {
+			positions[i] = cursor + idx
+			cursor += idx + len(w.Text)
+		}
+	}
+
+	aligned := make([]Timing, 0, len(words))
+	for i, w := range words {
+		if pos := positions[i]; pos >= 0 {
+			end := len(text)
+			if space := strings.IndexAny(text[pos:], " \t\r\n"); space >= 0 {
+				end = pos + space
+			}
+			for _, next := range positions[i+1:] {
+				if next >= 0 {
+					end = min(end, next)
+					break
+				}
+			}
+			w.Text = strings.TrimSpace(text[pos:end])
+		}
+		if w.Text != "" {
+			aligned = append(aligned, w)
+		}
+	}
+	return aligned
+}
+
+// wrapWords fills lines of at most width characters; longer words get a
+// line of their own
+func wrapWords(words []string, width int) []string {
+	var lines []string
+	var line string
+	for _, w := range words {
+		switch {
+		case line == "":
+			line = w
+		case width > 0 && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(w) > width:
+			lines = append(lines, line)
+			line = w
+		default:
+			line += " " + w
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// WriteSRT writes cues in SubRip format
+func WriteSRT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	for i, cue := range cues {
+		fmt.Fprintf(bw, "%d\n%s --> %s\n%s\n\n", i+1,
+			formatCueTime(cue.Start, ','), formatCueTime(cue.End, ','), strings.Join(cue.Lines, "\n"))
+	}
+	return bw.Flush()
+}
+
+// WriteVTT writes cues in WebVTT format
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(bw, "%s --> %s\n%s\n\n",
+			formatCueTime(cue.Start, '.'), formatCueTime(cue.End, '.'), escapeVTT(strings.Join(cue.Lines, "\n")))
+	}
+	return bw.Flush()
+}
+
+// formatCueTime formats d as hh:mm:ss followed by milliseconds
+func formatCueTime(d time.Duration, sep byte) string {
+	ms := d.Round(time.Millisecond).Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%c%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// escapeVTT escapes the characters WebVTT reserves for markup
+func escapeVTT(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// SynthToFileWithSubtitles synthesizes text to filename and writes matching
+// .srt and .vtt subtitles next to it. Providers that report word or
+// sentence boundaries are timed exactly; for others the words are spread
+// over the speech, measured by decoding the written audio.
+func SynthToFileWithSubtitles(ctx context.Context, provider TTSProvider, text, filename string, opts SubtitleOptions) error {
+	var timings []Timing
+	if timer, ok := provider.(TimedSynthesizer); ok {
+		var err error
+		if timings, err = timer.SynthToFileWithTimings(ctx, text, filename); err != nil {
+			return err
+		}
+	} else if err := provider.SynthToFile(ctx, text, filename); err != nil {
+		return err
+	}
+
+	// Providers can only time some input, e.g. plain text but not SSML
+	if len(wordTimings(timings)) == 0 {
+		start, end, err := speechSpan(filename)
+		if err != nil {
+			return fmt.Errorf("failed to measure speech for subtitles: %w", err)
+		}
+		timings = EstimateTimings(text, start, end)
+	}
+
+	cues := BuildCues(text, timings, opts)
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if err := writeSubtitleFile(base+".srt", cues, WriteSRT); err != nil {
+		return err
+	}
+	return writeSubtitleFile(base+".vtt", cues, WriteVTT)
+}
+
+// speechSpan returns when speech starts and ends in an audio file
+func speechSpan(filename string) (start, end time.Duration, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	samples, rate, channels, err := playback.Decode(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	first, last := audio.DetectSilence(samples, channels, rate, audio.DefaultTrimOptions)
+	toTime := func(frame int) time.Duration {
+		return time.Duration(float64(frame) / rate * float64(time.Second))
+	}
+	return toTime(first), toTime(last), nil
+}
+
+// writeSubtitleFile creates filename and writes cues to it
+func writeSubtitleFile(filename string, cues []Cue, write func(io.Writer, []Cue) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create subtitle file: %w", err)
+	}
+	if err := write(f, cues); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write subtitles: %w", err)
+	}
+	return f.Close()
+}
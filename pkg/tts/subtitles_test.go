@@ -0,0 +1,101 @@
+package tts_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/willwade/go-tts-wrapper/pkg/tts"
+)
+
+func word(text string, start, end time.Duration) tts.Timing {
+	return tts.Timing{Kind: tts.WordTiming, Text: text, Start: start, End: end}
+}
+
+func TestBuildCues(t *testing.T) {
+	ms := time.Millisecond
+	text := "Hello, world. This sentence is long enough that it has to be wrapped onto a second line."
+	// Word marks as a provider reports them, without punctuation
+	timings := []tts.Timing{
+		word("Hello", 0, 400*ms),
+		word("world", 500*ms, 900*ms),
+		word("This", 1500*ms, 1700*ms),
+		word("sentence", 1700*ms, 2200*ms),
+		word("is", 2200*ms, 2300*ms),
+		word("long", 2300*ms, 2600*ms),
+		word("enough", 2600*ms, 2900*ms),
+		word("that", 2900*ms, 3100*ms),
+		word("it", 3100*ms, 3200*ms),
+		word("has", 3200*ms, 3400*ms),
+		word("to", 3400*ms, 3500*ms),
+		word("be", 3500*ms, 3600*ms),
+		word("wrapped", 3600*ms, 4000*ms),
+		word("onto", 4000*ms, 4300*ms),
+		word("a", 4300*ms, 4400*ms),
+		word("second", 4400*ms, 4800*ms),
+		word("line", 4800*ms, 5200*ms),
+	}
+
+	opts := tts.DefaultSubtitleOptions()
+	opts.MaxLineLength = 32
+	cues := tts.BuildCues(text, timings, opts)
+
+	want := []tts.Cue{
+		// Held for the minimum duration
+		{Start: 0, End: time.Second, Lines: []string{"Hello, world."}},
+		// A third line would be needed, so the sentence is split; the cue is
+		// held for reading only until the next one starts
+		{Start: 1500 * ms, End: 4400 * ms, Lines: []string{"This sentence is long enough", "that it has to be wrapped onto a"}},
+		{Start: 4400 * ms, End: 5400 * ms, Lines: []string{"second line."}},
+	}
+	if !reflect.DeepEqual(cues, want) {
+		t.Errorf("got cues\n%+v\nwant\n%+v", cues, want)
+	}
+}
+
+func TestEstimateTimings(t *testing.T) {
+	timings := tts.EstimateTimings("One, two three.", time.Second, 3*time.Second)
+	if len(timings) != 3 {
+		t.Fatalf("got %d timings, want 3", len(timings))
+	}
+	if timings[0].Start != time.Second || timings[2].End != 3*time.Second {
+		t.Errorf("timings span %v-%v, want 1s-3s", timings[0].Start, timings[2].End)
+	}
+	// The comma adds a pause before "two"
+	if gap := timings[1].Start - timings[0].End; gap <= 0 {
+		t.Errorf("no pause after the comma")
+	}
+	for i := 1; i < len(timings); i++ {
+		if timings[i].Start < timings[i-1].End {
+			t.Errorf("timing %d starts before the previous one ends", i)
+		}
+	}
+}
+
+func TestWriteSubtitles(t *testing.T) {
+	cues := []tts.Cue{
+		{Start: 0, End: 1500 * time.Millisecond, Lines: []string{"Fish & chips"}},
+		{Start: time.Hour + 2*time.Second, End: time.Hour + 3*time.Second, Lines: []string{"<b>", "two lines"}},
+	}
+
+	var srt bytes.Buffer
+	if err := tts.WriteSRT(&srt, cues); err != nil {
+		t.Fatal(err)
+	}
+	wantSRT := "1\n00:00:00,000 --> 00:00:01,500\nFish & chips\n\n" +
+		"2\n01:00:02,000 --> 01:00:03,000\n<b>\ntwo lines\n\n"
+	if srt.String() != wantSRT {
+		t.Errorf("SRT:\n%q\nwant\n%q", srt.String(), wantSRT)
+	}
+
+	var vtt bytes.Buffer
+	if err := tts.WriteVTT(&vtt, cues); err != nil {
+		t.Fatal(err)
+	}
+	wantVTT := "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\nFish &amp; chips\n\n" +
+		"01:00:02.000 --> 01:00:03.000\n&lt;b&gt;\ntwo lines\n\n"
+	if vtt.String() != wantVTT {
+		t.Errorf("VTT:\n%q\nwant\n%q", vtt.String(), wantVTT)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 )
 
 // Voice represents a TTS voice with standardized properties
@@ -28,10 +30,16 @@ type TTSConfig struct {
 
 // AudioConfig contains settings for audio output
 type AudioConfig struct {
-	Rate     float64 // Speech rate (1.0 is normal)
-	Pitch    float64 // Voice pitch (1.0 is normal)
-	Volume   float64 // Volume level (1.0 is normal)
-	DeviceID string  // Output device ID
+	Rate       float64 // Speech rate (1.0 is normal)
+	Pitch      float64 // Voice pitch (1.0 is normal)
+	Volume     float64 // Volume level (1.0 is normal)
+	Pan        float64 // Stereo position (-1 left, 0 centre, 1 right)
+	DeviceID   string  // Output device ID
+	SampleRate int     // Output sample rate in Hz (0 keeps the source rate)
+
+	Normalization LoudnessNormalization // Loudness normalization (disabled by default)
+	TrimSilence   bool                  // Trim leading and trailing silence
+	Gaps          Gaps                  // Silence between sentences, paragraphs and utterances
 }
 
 // TTSProvider defines the interface that all TTS providers must implement
@@ -66,6 +74,12 @@ type TTSProvider interface {
 type BaseProvider struct {
 	config      TTSConfig
 	audioConfig AudioConfig
+	lexicon     *Lexicon
+	// ssmlEnvelope wraps lexicon SSML; nil uses a bare <speak> element
+	ssmlEnvelope SSMLEnvelope
+
+	handlersMu sync.Mutex
+	handlers   map[string][]func(interface{})
 }
 
 // NewBaseProvider creates a new base provider with the given config
@@ -76,6 +90,10 @@ func NewBaseProvider(config TTSConfig) *BaseProvider {
 			Rate:   1.0,
 			Pitch:  1.0,
 			Volume: 1.0,
+			Normalization: LoudnessNormalization{
+				TargetLUFS: -16,
+				TruePeak:   -1,
+			},
 		},
 	}
 }
@@ -98,6 +116,74 @@ func (b *BaseProvider) SetProperty(property string, value interface{}) error {
 			b.audioConfig.Volume = volume
 			return nil
 		}
+	case "pan":
+		if pan, ok := value.(float64); ok {
+			if pan < -1 || pan > 1 {
+				return fmt.Errorf("pan must be between -1 and 1, got %v", pan)
+			}
+			b.audioConfig.Pan = pan
+			return nil
+		}
+	case "normalize":
+		if enabled, ok := value.(bool); ok {
+			b.audioConfig.Normalization.Enabled = enabled
+			return nil
+		}
+	case "loudness":
+		if lufs, ok := value.(float64); ok {
+			if lufs >= 0 {
+				return fmt.Errorf("loudness target must be negative LUFS, got %v", lufs)
+			}
+			b.audioConfig.Normalization.TargetLUFS = lufs
+			b.audioConfig.Normalization.Enabled = true
+			return nil
+		}
+	case "true_peak":
+		if peak, ok := value.(float64); ok {
+			if peak > 0 {
+				return fmt.Errorf("true peak limit must be at most 0 dBTP, got %v", peak)
+			}
+			b.audioConfig.Normalization.TruePeak = peak
+			return nil
+		}
+	case "sample_rate":
+		if rate, ok := value.(int); ok {
+			if rate < 0 {
+				return fmt.Errorf("sample rate must not be negative, got %d", rate)
+			}
+			b.audioConfig.SampleRate = rate
+			return nil
+		}
+	case "trim_silence":
+		if trim, ok := value.(bool); ok {
+			b.audioConfig.TrimSilence = trim
+			return nil
+		}
+	case "sentence_gap", "paragraph_gap", "utterance_gap":
+		if gap, ok := value.(time.Duration); ok {
+			if gap < 0 {
+				return fmt.Errorf("%s must not be negative, got %v", property, gap)
+			}
+			switch property {
+			case "sentence_gap":
+				b.audioConfig.Gaps.Sentence = gap
+			case "paragraph_gap":
+				b.audioConfig.Gaps.Paragraph = gap
+			default:
+				b.audioConfig.Gaps.Utterance = gap
+			}
+			return nil
+		}
+	case "device":
+		if deviceID, ok := value.(string); ok {
+			b.audioConfig.DeviceID = deviceID
+			return nil
+		}
+	case "lexicon":
+		if lexicon, ok := value.(*Lexicon); ok {
+			b.lexicon = lexicon
+			return nil
+		}
 	}
 	return fmt.Errorf("invalid property or value type: %s", property)
 }